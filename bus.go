@@ -1,17 +1,55 @@
 package main
 
+// Cpu Memory Map
+// https://www.nesdev.org/wiki/CPU_memory_map
+const (
+	RAM_MIRRORS_END           = 0x1fff
+	PPU_REGISTERS_MIRRORS_END = 0x3fff
+	OAMDMA                    = 0x4014
+	INTERRUPT_VECTORS_START   = 0xfffa
+)
+
 type Bus struct {
 	cpuVRam [2048]uint8
+	ppu     Ppu
+	// Set when a write has kept the bus busy and the cpu needs to wait for it.
+	dmaPending bool
 	// TODO(mjpatter88): fix this hack once roms are supported.
-	startingPC uint16
+	startingPC       uint16
+	interruptVectors [6]uint8
 }
 
 func (b *Bus) ReadMemory(address uint16) uint8 {
-	return b.cpuVRam[address]
+	switch {
+	case address <= RAM_MIRRORS_END:
+		// 2KB of ram mirrored four times.
+		return b.cpuVRam[address&0x07ff]
+	case address <= PPU_REGISTERS_MIRRORS_END:
+		// 8 registers mirrored every 8 bytes.
+		return b.ppu.ReadRegister(PPUCTRL | (address & 0x0007))
+	case address >= INTERRUPT_VECTORS_START:
+		// TODO(mjpatter88): fix this hack once roms are supported.
+		return b.interruptVectors[address-INTERRUPT_VECTORS_START]
+	default:
+		// TODO(mjpatter88): apu, io and cartridge space aren't supported yet.
+		return 0
+	}
 }
 
 func (b *Bus) WriteMemory(address uint16, value uint8) {
-	b.cpuVRam[address] = value
+	switch {
+	case address <= RAM_MIRRORS_END:
+		b.cpuVRam[address&0x07ff] = value
+	case address <= PPU_REGISTERS_MIRRORS_END:
+		b.ppu.WriteRegister(PPUCTRL|(address&0x0007), value)
+	case address == OAMDMA:
+		b.oamDma(value)
+	case address >= INTERRUPT_VECTORS_START:
+		// TODO(mjpatter88): fix this hack once roms are supported.
+		b.interruptVectors[address-INTERRUPT_VECTORS_START] = value
+	default:
+		// TODO(mjpatter88): apu, io and cartridge space aren't supported yet.
+	}
 }
 
 // nes is little-endian so 16-bit values read from memory need to handle this byte order.
@@ -40,3 +78,42 @@ func (b *Bus) WriteMemory_u16(address uint16, value uint16) {
 	b.WriteMemory(address, uint8(firstByte))
 	b.WriteMemory(address+1, uint8(secondByte))
 }
+
+// Copy a page of cpu memory (0xXX00 - 0xXXff) into oam.
+// The copy starts at OAMADDR and wraps around, just like writing to OAMDATA 256 times.
+//
+// See: https://www.nesdev.org/wiki/PPU_registers#OAMDMA
+func (b *Bus) oamDma(page uint8) {
+	start := uint16(page) << 8
+	for i := uint16(0); i < 256; i++ {
+		b.ppu.WriteRegister(OAMDATA, b.ReadMemory(start+i))
+	}
+	b.dmaPending = true
+}
+
+// Returns the number of cycles the cpu has to stall for, given the number of
+// cycles it has run so far.
+//
+// OAM DMA takes 513 cycles: one to wait for the write to finish, then 256 reads
+// interleaved with 256 writes. Reads have to happen on even cycles, so it takes
+// one more cycle to line up if it starts on an odd cycle.
+func (b *Bus) pollStall(cpuCycles uint64) int {
+	if !b.dmaPending {
+		return 0
+	}
+	b.dmaPending = false
+	if cpuCycles%2 == 1 {
+		return 514
+	}
+	return 513
+}
+
+// Advance the rest of the system to keep up with the cpu.
+func (b *Bus) tick(cycles int) {
+	b.ppu.Tick(cycles * 3)
+}
+
+// Returns true if an nmi is waiting to be serviced by the cpu.
+func (b *Bus) pollNmi() bool {
+	return b.ppu.PollNmi()
+}
@@ -61,3 +61,86 @@ func TestWriteMemory_u16(t *testing.T) {
 		t.Errorf("wanted %#x but got %#x", 0x11, secondByte)
 	}
 }
+
+func TestRamMirroring(t *testing.T) {
+	bus := Bus{}
+	bus.WriteMemory(0x0805, 0x66)
+
+	for _, address := range []uint16{0x0005, 0x0805, 0x1005, 0x1805} {
+		byte := bus.ReadMemory(address)
+		if byte != 0x66 {
+			t.Errorf("wanted %#x at %#x but got %#x", 0x66, address, byte)
+		}
+	}
+}
+
+func TestPpuRegisterMirroring(t *testing.T) {
+	bus := Bus{}
+	// 0x3455 mirrors PPUSCROLL (0x2005)
+	bus.WriteMemory(0x3455, 0x10)
+	bus.WriteMemory(0x3455, 0x20)
+
+	// coarse x = 2, coarse y = 4
+	if bus.ppu.t != 0x0082 {
+		t.Errorf("wanted %#x but got %#x", 0x0082, bus.ppu.t)
+	}
+}
+
+func TestInterruptVectors(t *testing.T) {
+	bus := Bus{}
+	bus.WriteMemory_u16(NMI_VECTOR_MEM_ADDRESS, 0x1234)
+
+	value := bus.ReadMemory_u16(NMI_VECTOR_MEM_ADDRESS)
+	if value != 0x1234 {
+		t.Errorf("wanted %#x but got %#x", 0x1234, value)
+	}
+}
+
+func TestOamDma(t *testing.T) {
+	t.Run("Copies a page", func(t *testing.T) {
+		bus := Bus{}
+		for i := 0; i < 256; i++ {
+			bus.cpuVRam[0x0300+i] = uint8(i)
+		}
+		bus.WriteMemory(OAMDMA, 0x03)
+
+		for i := 0; i < 256; i++ {
+			if bus.ppu.oamData[i] != uint8(i) {
+				t.Fatalf("wanted %#x at oam[%#x] but got %#x", i, i, bus.ppu.oamData[i])
+			}
+		}
+	})
+
+	t.Run("Starts at OAMADDR", func(t *testing.T) {
+		bus := Bus{}
+		for i := 0; i < 256; i++ {
+			bus.cpuVRam[0x0300+i] = uint8(i)
+		}
+		bus.WriteMemory(OAMADDR, 0x10)
+		bus.WriteMemory(OAMDMA, 0x03)
+
+		if bus.ppu.oamData[0x10] != 0x00 || bus.ppu.oamData[0x0f] != 0xff {
+			t.Errorf("wanted oam to start at 0x10 and wrap, but got oam[0x10] = %#x and oam[0x0f] = %#x", bus.ppu.oamData[0x10], bus.ppu.oamData[0x0f])
+		}
+	})
+}
+
+func TestPollStall(t *testing.T) {
+	bus := Bus{}
+	if stall := bus.pollStall(0); stall != 0 {
+		t.Errorf("wanted no stall but got %d", stall)
+	}
+
+	bus.WriteMemory(OAMDMA, 0x03)
+	if stall := bus.pollStall(100); stall != 513 {
+		t.Errorf("wanted %d but got %d", 513, stall)
+	}
+	if stall := bus.pollStall(100); stall != 0 {
+		t.Errorf("wanted the stall to be cleared but got %d", stall)
+	}
+
+	bus.WriteMemory(OAMDMA, 0x03)
+	if stall := bus.pollStall(101); stall != 514 {
+		t.Errorf("wanted %d but got %d", 514, stall)
+	}
+}
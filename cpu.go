@@ -8,7 +8,9 @@ const (
 	// Thid should be 0x8000, but that breaks since that address
 	// will be part of the ROM address space.
 	DEFAULT_PROG_MEM_ADDRESS   = 0x0200
+	NMI_VECTOR_MEM_ADDRESS     = 0xfffa
 	PROG_REFERENCE_MEM_ADDRESS = 0xfffc
+	STACK_MEM_ADDRESS          = 0x0100
 )
 
 type StatusRegister struct {
@@ -33,6 +35,7 @@ type Cpu struct {
 	// structure that owns the cpu, bus, ppu, etc?
 	bus          Bus
 	StackPointer uint8
+	Cycles       uint64
 }
 
 func (c *Cpu) readMemory(index uint16) uint8 {
@@ -75,13 +78,20 @@ func (c *Cpu) run() {
 	}
 }
 
-// Executes a single instruction.
+// Executes a single instruction, or services a pending interrupt instead.
 // TODO(mjpatter88): maybe return the number of cycles?
 func (c *Cpu) Step() {
+	if c.bus.pollNmi() {
+		c.interruptNmi()
+		return
+	}
+
 	opcode := c.bus.ReadMemory(c.ProgramCounter)
 	instr := Decode(opcode)
+	pc := c.ProgramCounter
 
 	didJump := false
+	pageCrossed := false
 	var param uint16
 
 	switch instr.AddressingMode {
@@ -91,8 +101,10 @@ func (c *Cpu) Step() {
 		param = c.AbsoluteMode()
 	case ABSOLUTE_X:
 		param = c.AbsoluteXMode()
+		pageCrossed = crossesPage(param-uint16(c.RegX), param)
 	case ABSOLUTE_Y:
 		param = c.AbsoluteYMode()
+		pageCrossed = crossesPage(param-uint16(c.RegY), param)
 	case ZERO:
 		param = c.ZeroMode()
 	case ZERO_X:
@@ -109,7 +121,21 @@ func (c *Cpu) Step() {
 		param = c.IndirectXMode()
 	case INDIRECT_Y:
 		param = c.IndirectYMode()
+		// On hardware Y is added to the pointer read from the zero page, and that
+		// sum decides whether a page was crossed.
+		// TODO(mjpatter88): IndirectYMode adds Y before reading the pointer instead.
+		pointer := c.bus.ReadMemory_u16(uint16(c.bus.ReadMemory(c.ProgramCounter + 1)))
+		pageCrossed = crossesPage(pointer, pointer+uint16(c.RegY))
+	}
+
+	cycles := instr.Cycles
+	if pageCrossed && hasPageCrossingPenalty(instr.Action) {
+		cycles++
 	}
+	// Instructions read or write their operand on their last cycle. Catch the rest
+	// of the system up to that point first so ppu register accesses land on the
+	// right dot, which mid-frame raster effects depend on.
+	c.tick(cycles - 1)
 
 	switch instr.Action {
 	case "BIT":
@@ -166,6 +192,9 @@ func (c *Cpu) Step() {
 	case "RTS":
 		c.instrRTS(param)
 		didJump = true
+	case "RTI":
+		c.instrRTI()
+		didJump = true
 	case "BPL":
 		didJump = c.instrBPL(param)
 	case "BMI":
@@ -202,6 +231,61 @@ func (c *Cpu) Step() {
 		c.ProgramCounter += uint16(instr.NumberOfBytes)
 	}
 
+	remaining := 1
+	// Taken branches take an extra cycle, and another if they land on a different page.
+	if instr.AddressingMode == RELATIVE && didJump {
+		remaining++
+		if crossesPage(pc+uint16(instr.NumberOfBytes), c.ProgramCounter) {
+			remaining++
+		}
+	}
+	c.tick(remaining)
+
+	// The bus can halt the cpu (OAM DMA). The rest of the system keeps running.
+	if stall := c.bus.pollStall(c.Cycles); stall > 0 {
+		c.tick(stall)
+	}
+}
+
+// Count cycles and run the rest of the system for the same amount of time.
+func (c *Cpu) tick(cycles int) {
+	c.Cycles += uint64(cycles)
+	c.bus.tick(cycles)
+}
+
+// Push the program counter and status onto the stack and jump to the nmi handler.
+//
+// See: https://www.nesdev.org/wiki/CPU_interrupts
+func (c *Cpu) interruptNmi() {
+	c.stackPush_u16(c.ProgramCounter)
+
+	// The break flag only exists on the stack. It's clear for hardware interrupts.
+	status := c.Status
+	status.Break = false
+	status.Unused = true
+	c.stackPush(status.toByte())
+
+	c.Status.Interrupt = true
+	c.ProgramCounter = c.bus.ReadMemory_u16(NMI_VECTOR_MEM_ADDRESS)
+
+	c.tick(7)
+}
+
+func crossesPage(from uint16, to uint16) bool {
+	return from&0xff00 != to&0xff00
+}
+
+// Indexed reads take an extra cycle to fix up the high byte of the address when
+// it crosses a page. Stores and read-modify-write instructions always take that
+// cycle, so it's already part of their base count.
+//
+// See: https://www.nesdev.org/wiki/6502_cycle_times
+func hasPageCrossingPenalty(action string) bool {
+	switch action {
+	case "STA", "STX", "STY", "LSR", "ASL", "ROL", "ROR", "INC", "DEC":
+		return false
+	}
+	return true
 }
 
 func (c *Cpu) PrintState() {
@@ -218,6 +302,33 @@ func (c *Cpu) reset() {
 	c.StackPointer = 0xff
 }
 
+// Pack the status flags into a byte in the order they're pushed to the stack.
+// 7 6 5 4 3 2 1 0
+// N V U B D I Z C
+func (s StatusRegister) toByte() uint8 {
+	flags := []bool{s.Carry, s.Zero, s.Interrupt, s.Decimal, s.Break, s.Unused, s.Overflow, s.Negative}
+	var value uint8
+	for bit, set := range flags {
+		if set {
+			value |= 1 << bit
+		}
+	}
+	return value
+}
+
+func statusFromByte(value uint8) StatusRegister {
+	return StatusRegister{
+		Carry:     value&(1<<0) != 0,
+		Zero:      value&(1<<1) != 0,
+		Interrupt: value&(1<<2) != 0,
+		Decimal:   value&(1<<3) != 0,
+		Break:     value&(1<<4) != 0,
+		Unused:    value&(1<<5) != 0,
+		Overflow:  value&(1<<6) != 0,
+		Negative:  value&(1<<7) != 0,
+	}
+}
+
 func (c *Cpu) updateFlags(result uint8) {
 	c.Status.Zero = (result == 0)
 	c.Status.Negative = ((result & (1 << 7)) != 0)
@@ -402,22 +513,22 @@ func (c *Cpu) instrSEC() {
 }
 
 func (c *Cpu) instrJSR(param uint16) {
-	// We'll write the two bytes at once, so write it to SP - 1 (ends up writing to SP-1 and SP)
-	index := 0x0100 | uint16((c.StackPointer - 1))
 	// JSR length is 3 and we want to store the address of the next insturction - 1.
-	value := c.ProgramCounter + 3 - 1
-	c.bus.WriteMemory_u16(index, value)
-
-	c.StackPointer -= 2
+	c.stackPush_u16(c.ProgramCounter + 3 - 1)
 	c.ProgramCounter = param
 }
 
 func (c *Cpu) instrRTS(param uint16) {
-	// Read two bytes from the top of the stack.
-	index := 0x0100 | uint16((c.StackPointer + 1))
-	value := c.bus.ReadMemory_u16(index)
-	c.StackPointer += 2
-	c.ProgramCounter = value + 1
+	c.ProgramCounter = c.stackPop_u16() + 1
+}
+
+func (c *Cpu) instrRTI() {
+	// The break flag is ignored when pulling the status from the stack.
+	status := statusFromByte(c.stackPop())
+	status.Break = c.Status.Break
+	c.Status = status
+	// Unlike RTS, the address on the stack is the exact return address.
+	c.ProgramCounter = c.stackPop_u16()
 }
 
 // Returns true if branch was taken, false otherwise
@@ -601,4 +712,23 @@ func (c *Cpu) RelativeMode() uint16 {
 // Stack pointer starts at 0xff refers to 0x01ff in memory.
 // It grows downwards, so when a byte is added the next SP value is 0xfe.
 // When adding addresses (such as JSR) the MSB is added first: 0x8000 -> 0x80 then 0x00
-// TODO(mjpatter88): refactor stack management into helpers.
+func (c *Cpu) stackPush(value uint8) {
+	c.bus.WriteMemory(STACK_MEM_ADDRESS|uint16(c.StackPointer), value)
+	c.StackPointer--
+}
+
+func (c *Cpu) stackPop() uint8 {
+	c.StackPointer++
+	return c.bus.ReadMemory(STACK_MEM_ADDRESS | uint16(c.StackPointer))
+}
+
+func (c *Cpu) stackPush_u16(value uint16) {
+	c.stackPush(uint8(value >> 8))
+	c.stackPush(uint8(value & 0xff))
+}
+
+func (c *Cpu) stackPop_u16() uint16 {
+	lsb := uint16(c.stackPop())
+	msb := uint16(c.stackPop())
+	return (msb << 8) | lsb
+}
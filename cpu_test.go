@@ -999,6 +999,208 @@ func TestJSR(t *testing.T) {
 	AssertStackPointer(t, &cpu, 0x00fd)
 }
 
+func TestRTI(t *testing.T) {
+	cpu := Cpu{}
+	cpu.StackPointer = 0xfc
+	cpu.bus.cpuVRam[0x01fd] = 0xc3
+	cpu.bus.cpuVRam[0x01fe] = 0x34
+	cpu.bus.cpuVRam[0x01ff] = 0x12
+	cpu.instrRTI()
+
+	AssertProgramCounter(t, &cpu, 0x1234)
+	AssertStackPointer(t, &cpu, 0xff)
+	AssertCarry(t, &cpu, true)
+	AssertZero(t, &cpu, true)
+	AssertNegative(t, &cpu, true)
+	AssertOverflow(t, &cpu, true)
+	// The break flag on the stack is ignored.
+	AssertBreak(t, &cpu, false)
+}
+
+func TestStatusToByte(t *testing.T) {
+	status := StatusRegister{Carry: true, Interrupt: true, Unused: true, Negative: true}
+	value := status.toByte()
+	if value != 0xa5 {
+		t.Errorf("Expected %#x but got %#x", 0xa5, value)
+	}
+
+	if statusFromByte(value) != status {
+		t.Errorf("Expected %+v but got %+v", status, statusFromByte(value))
+	}
+}
+
+func TestNmi(t *testing.T) {
+	t.Run("Interrupt", func(t *testing.T) {
+		cpu := Cpu{}
+		cpu.ProgramCounter = 0x0234
+		cpu.StackPointer = 0xff
+		cpu.Status.Carry = true
+		cpu.bus.WriteMemory_u16(NMI_VECTOR_MEM_ADDRESS, 0x0300)
+		cpu.interruptNmi()
+
+		AssertProgramCounter(t, &cpu, 0x0300)
+		AssertStackPointer(t, &cpu, 0xfc)
+		AssertMemoryValue(t, &cpu, 0x01ff, 0x02)
+		AssertMemoryValue(t, &cpu, 0x01fe, 0x34)
+		// Carry and Unused set, Break clear.
+		AssertMemoryValue(t, &cpu, 0x01fd, 0x21)
+		if !cpu.Status.Interrupt {
+			t.Errorf("Expected Interrupt status to be true")
+		}
+	})
+
+	t.Run("Serviced on vblank", func(t *testing.T) {
+		cpu := Cpu{}
+		cpu.bus.WriteMemory_u16(NMI_VECTOR_MEM_ADDRESS, 0x0300)
+		// The handler increments x and returns.
+		cpu.bus.cpuVRam[0x0300] = INX
+		cpu.bus.cpuVRam[0x0301] = RTI
+		// Enable nmi, then spin until x is incremented.
+		cpu.Load([]uint8{LDA, CTRL_GENERATE_NMI, STA_ABS, 0x00, 0x20, CPX, 0x01, BNE, 0xfc, BRK})
+		cpu.run()
+
+		AssertRegisterX(t, &cpu, 0x01)
+		AssertProgramCounter(t, &cpu, 0x020a)
+	})
+}
+
+func TestCycles(t *testing.T) {
+	t.Run("Base cycles", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 3 + 2 + 7
+		cpu.Execute([]uint8{LDA, 0x01, STA_ZERO, 0x10, INX, BRK})
+
+		AssertCycles(t, &cpu, 14)
+		if cpu.bus.ppu.Dot != 14*3 {
+			t.Errorf("Expected ppu dot to be %d but was %d", 14*3, cpu.bus.ppu.Dot)
+		}
+	})
+
+	t.Run("Branch not taken", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 2 + 7
+		cpu.Execute([]uint8{LDA, 0x00, BNE, 0x00, BRK})
+
+		AssertCycles(t, &cpu, 11)
+	})
+
+	t.Run("Branch taken", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 3 + 7
+		cpu.Execute([]uint8{LDA, 0x01, BNE, 0x00, BRK})
+
+		AssertCycles(t, &cpu, 12)
+	})
+
+	t.Run("Branch taken to another page", func(t *testing.T) {
+		cpu := Cpu{}
+		// The branch ends at 0x02fe and lands on the BRK at 0x0300.
+		// 2 + 4 + 7
+		cpu.ExecuteAtAddress([]uint8{LDA, 0x01, BNE, 0x02, NOP, NOP, BRK}, 0x02fa)
+
+		AssertCycles(t, &cpu, 13)
+	})
+
+	t.Run("Indexed read crossing a page", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 4 + 4 + 1 + 7
+		cpu.Execute([]uint8{LDX, 0x01, LDA_ABS_X, 0x10, 0x01, LDA_ABS_X, 0xff, 0x01, BRK})
+
+		AssertCycles(t, &cpu, 18)
+	})
+
+	t.Run("Indirect indexed read crossing a page", func(t *testing.T) {
+		cpu := Cpu{}
+		cpu.bus.cpuVRam[0x10] = 0xff
+		cpu.bus.cpuVRam[0x11] = 0x01
+		// 2 + 5 + 1 + 7
+		cpu.Execute([]uint8{LDY, 0x01, LDA_IND_Y, 0x10, BRK})
+
+		AssertCycles(t, &cpu, 15)
+	})
+
+	t.Run("Indexed store crossing a page", func(t *testing.T) {
+		cpu := Cpu{}
+		// Stores always take the extra cycle, so there's no penalty.
+		// 2 + 5 + 7
+		cpu.Execute([]uint8{LDX, 0x01, STA_ABS_X, 0xff, 0x01, BRK})
+
+		AssertCycles(t, &cpu, 14)
+	})
+}
+
+func TestPpuAccessTiming(t *testing.T) {
+	t.Run("Writes land on the last cycle", func(t *testing.T) {
+		cpu := Cpu{}
+		cpu.Load([]uint8{LDA, MASK_EMPHASIZE_BLUE, STA_ABS, 0x01, 0x20, BRK})
+		cpu.bus.ppu.Scanline = 10
+		cpu.Step()
+		cpu.Step()
+
+		// LDA runs dots 1-6. STA runs dots 7-18 and writes after dot 15, so the
+		// pixel on dot 16 (x = 15) is the first one emphasized.
+		AssertEmphasizedPixel(t, &cpu.bus.ppu.FrameBuffer, 14, 10, 0x00, 0x00)
+		AssertEmphasizedPixel(t, &cpu.bus.ppu.FrameBuffer, 15, 10, 0x00, 0x04)
+	})
+
+	t.Run("Reads land on the last cycle", func(t *testing.T) {
+		// vblank starts on dot 1 of scanline 241. LDA takes 4 cycles (12 dots)
+		// and reads after the first 9.
+		for _, test := range []struct {
+			dot    int
+			vblank bool
+		}{{DOTS_PER_SCANLINE - 9, false}, {DOTS_PER_SCANLINE - 8, true}} {
+			cpu := Cpu{}
+			cpu.Load([]uint8{LDA_ABS, 0x02, 0x20, BRK})
+			cpu.bus.ppu.Scanline = VBLANK_SCANLINE - 1
+			cpu.bus.ppu.Dot = test.dot
+			cpu.Step()
+
+			if vblank := cpu.RegA&STATUS_VBLANK != 0; vblank != test.vblank {
+				t.Errorf("Expected vblank to be %t starting from dot %d but was %t", test.vblank, test.dot, vblank)
+			}
+		}
+	})
+}
+
+func TestOamDmaStall(t *testing.T) {
+	t.Run("Even cycle", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 4 cycles, so the dma starts on an even cycle.
+		cpu.Load([]uint8{LDA, 0x03, STA_ABS, 0x14, 0x40, BRK})
+		cpu.Step()
+		cpu.Step()
+
+		AssertCycles(t, &cpu, 6+513)
+		ppuDots := cpu.bus.ppu.Scanline*DOTS_PER_SCANLINE + cpu.bus.ppu.Dot
+		if ppuDots != (6+513)*3 {
+			t.Errorf("Expected the ppu to have run %d dots but it ran %d", (6+513)*3, ppuDots)
+		}
+	})
+
+	t.Run("Odd cycle", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 3 + 4 cycles, so the dma starts on an odd cycle.
+		cpu.Load([]uint8{LDA, 0x03, STA_ZERO, 0x10, STA_ABS, 0x14, 0x40, BRK})
+		cpu.Step()
+		cpu.Step()
+		cpu.Step()
+
+		AssertCycles(t, &cpu, 9+514)
+	})
+
+	t.Run("After a taken branch", func(t *testing.T) {
+		cpu := Cpu{}
+		// 2 + 3 + 4 cycles. The taken branch's extra cycle puts the dma on an odd cycle.
+		cpu.Load([]uint8{LDA, 0x03, BNE, 0x00, STA_ABS, 0x14, 0x40, BRK})
+		cpu.Step()
+		cpu.Step()
+		cpu.Step()
+
+		AssertCycles(t, &cpu, 9+514)
+	})
+}
+
 // Exercise sets of instructions that utilize various addressing modes
 func TestAddressingModeInstructionExecution(t *testing.T) {
 	t.Run("Zero Page", func(t *testing.T) {
@@ -1623,3 +1825,9 @@ func AssertStackPointer(t *testing.T, cpu *Cpu, value uint8) {
 		t.Errorf("Expected stack pointer to be %#x but was %#x", value, cpu.StackPointer)
 	}
 }
+
+func AssertCycles(t *testing.T, cpu *Cpu, value uint64) {
+	if cpu.Cycles != value {
+		t.Errorf("Expected cycles to be %d but was %d", value, cpu.Cycles)
+	}
+}
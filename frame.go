@@ -0,0 +1,43 @@
+package main
+
+import (
+	"image"
+)
+
+const (
+	FRAME_WIDTH  = 256
+	FRAME_HEIGHT = 240
+)
+
+// The rendered output of the ppu. Each pixel is the color from palette ram along
+// with the emphasis bits that were set when it was drawn (emphasis<<6 | color),
+// which is also its index into a Palette. Turning it into rgb is left until
+// the frame is displayed so any palette can be used.
+type FrameBuffer struct {
+	Pixels [FRAME_WIDTH * FRAME_HEIGHT]uint16
+}
+
+func (f *FrameBuffer) SetPixel(x int, y int, color uint8, emphasis uint8) {
+	f.Pixels[y*FRAME_WIDTH+x] = uint16(emphasis&0x07)<<6 | uint16(color&0x3f)
+}
+
+func (f *FrameBuffer) Pixel(x int, y int) uint16 {
+	return f.Pixels[y*FRAME_WIDTH+x]
+}
+
+// Convert the frame to rgb using the given palette, or systemPalette when nil.
+// The pixel bytes can be copied straight into an RGBA texture.
+func (f *FrameBuffer) Image(palette *Palette) *image.RGBA {
+	if palette == nil {
+		palette = defaultPalette
+	}
+	img := image.NewRGBA(image.Rect(0, 0, FRAME_WIDTH, FRAME_HEIGHT))
+	for i, pixel := range f.Pixels {
+		c := palette[pixel]
+		img.Pix[i*4] = c.R
+		img.Pix[i*4+1] = c.G
+		img.Pix[i*4+2] = c.B
+		img.Pix[i*4+3] = c.A
+	}
+	return img
+}
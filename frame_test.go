@@ -0,0 +1,41 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestFrameBuffer(t *testing.T) {
+	t.Run("Stores color and emphasis", func(t *testing.T) {
+		frame := FrameBuffer{}
+		frame.SetPixel(10, 20, 0x16, 0x05)
+
+		if actual := frame.Pixel(10, 20); actual != 0x0156 {
+			t.Errorf("Expected pixel to be %#x but was %#x", 0x0156, actual)
+		}
+	})
+
+	t.Run("Image uses the palette", func(t *testing.T) {
+		palette := Palette{}
+		palette[0x16] = color.RGBA{0x11, 0x22, 0x33, 0xff}
+		palette[0x05<<6|0x16] = color.RGBA{0x44, 0x55, 0x66, 0xff}
+		frame := FrameBuffer{}
+		frame.SetPixel(0, 0, 0x16, 0)
+		frame.SetPixel(FRAME_WIDTH-1, FRAME_HEIGHT-1, 0x16, 0x05)
+		img := frame.Image(&palette)
+
+		AssertColor(t, img.RGBAAt(0, 0), palette[0x16])
+		AssertColor(t, img.RGBAAt(FRAME_WIDTH-1, FRAME_HEIGHT-1), palette[0x05<<6|0x16])
+		AssertColor(t, img.RGBAAt(1, 0), palette[0x00])
+	})
+
+	t.Run("Falls back to the system palette", func(t *testing.T) {
+		frame := FrameBuffer{}
+		frame.SetPixel(0, 0, 0x16, 0)
+		frame.SetPixel(1, 0, 0x16, 0x04)
+		img := frame.Image(nil)
+
+		AssertColor(t, img.RGBAAt(0, 0), systemPalette[0x16])
+		AssertColor(t, img.RGBAAt(1, 0), defaultPalette.Color(0x16, 0x04))
+	})
+}
@@ -2,4 +2,4 @@ module github.com/mjpatter88/go-nes
 
 go 1.17
 
-require github.com/veandco/go-sdl2 v0.4.10 // indirect
+require github.com/veandco/go-sdl2 v0.4.10
@@ -8,6 +8,7 @@ const (
 	SEC = 0x38
 	JSR = 0x20
 	RTS = 0x60
+	RTI = 0x40
 
 	BIT_ZERO = 0x24
 	BIT_ABS  = 0x2c
@@ -146,6 +147,9 @@ type Instruction struct {
 	Action         string
 	AddressingMode int
 	NumberOfBytes  int
+	// Base cycle count. The cpu adds the extra cycles for page crossings and
+	// taken branches.
+	Cycles int
 }
 
 func Decode(opcode uint8) Instruction {
@@ -153,106 +157,107 @@ func Decode(opcode uint8) Instruction {
 }
 
 var instructionMap = map[uint8]Instruction{
-	0x00: {"BRK", IMPLICIT, 1},
-	0xea: {"NOP", IMPLICIT, 1},
-	0x18: {"CLC", IMPLICIT, 1},
-	0x38: {"SEC", IMPLICIT, 1},
-	0x20: {"JSR", ABSOLUTE, 3},
-	0x24: {"BIT", ZERO, 2},
-	0x2c: {"BIT", ABSOLUTE, 3},
-	0x60: {"RTS", IMPLICIT, 1},
-	0xa9: {"LDA", IMMEDIATE, 2},
-	0xa5: {"LDA", ZERO, 2},
-	0xb5: {"LDA", ZERO_X, 2},
-	0xad: {"LDA", ABSOLUTE, 3},
-	0xbd: {"LDA", ABSOLUTE_X, 3},
-	0xb9: {"LDA", ABSOLUTE_Y, 3},
-	0xa1: {"LDA", INDIRECT_X, 2},
-	0xb1: {"LDA", INDIRECT_Y, 2},
-	0xa2: {"LDX", IMMEDIATE, 2},
-	0xa6: {"LDX", ZERO, 2},
-	0xb6: {"LDX", ZERO_Y, 2},
-	0xae: {"LDX", ABSOLUTE, 3},
-	0xbe: {"LDX", ABSOLUTE_Y, 3},
-	0xa0: {"LDY", IMMEDIATE, 2},
-	0xa4: {"LDY", ZERO, 2},
-	0xb4: {"LDY", ZERO_X, 2},
-	0xac: {"LDY", ABSOLUTE, 3},
-	0xbc: {"LDY", ABSOLUTE_X, 3},
-	0x4a: {"LSR", ACCUMULATOR, 1},
-	0x46: {"LSR", ZERO, 2},
-	0x56: {"LSR", ZERO_X, 2},
-	0x4e: {"LSR", ABSOLUTE, 3},
-	0x5e: {"LSR", ABSOLUTE_X, 3},
-	0x29: {"AND", IMMEDIATE, 2},
-	0x25: {"AND", ZERO, 2},
-	0x35: {"AND", ZERO_X, 2},
-	0x2d: {"AND", ABSOLUTE, 3},
-	0x3d: {"AND", ABSOLUTE_X, 3},
-	0x39: {"AND", ABSOLUTE_Y, 3},
-	0x21: {"AND", INDIRECT_X, 2},
-	0x31: {"AND", INDIRECT_Y, 2},
-	0x69: {"ADC", IMMEDIATE, 2},
-	0x65: {"ADC", ZERO, 2},
-	0x75: {"ADC", ZERO_X, 2},
-	0x6d: {"ADC", ABSOLUTE, 3},
-	0x7d: {"ADC", ABSOLUTE_X, 3},
-	0x79: {"ADC", ABSOLUTE_Y, 3},
-	0x61: {"ADC", INDIRECT_X, 2},
-	0x71: {"ADC", INDIRECT_Y, 2},
-	0xe9: {"SBC", IMMEDIATE, 2},
-	0xe5: {"SBC", ZERO, 2},
-	0xf5: {"SBC", ZERO_X, 2},
-	0xed: {"SBC", ABSOLUTE, 3},
-	0xfd: {"SBC", ABSOLUTE_X, 3},
-	0xf9: {"SBC", ABSOLUTE_Y, 3},
-	0xe1: {"SBC", INDIRECT_X, 2},
-	0xf1: {"SBC", INDIRECT_Y, 2},
-	0xc9: {"CMP", IMMEDIATE, 2},
-	0xc5: {"CMP", ZERO, 2},
-	0xd5: {"CMP", ZERO_X, 2},
-	0xcd: {"CMP", ABSOLUTE, 3},
-	0xdd: {"CMP", ABSOLUTE_X, 3},
-	0xd9: {"CMP", ABSOLUTE_Y, 3},
-	0xc1: {"CMP", INDIRECT_X, 2},
-	0xd1: {"CMP", INDIRECT_Y, 2},
-	0xe0: {"CPX", IMMEDIATE, 2},
-	0xe4: {"CPX", ZERO, 2},
-	0xec: {"CPX", ABSOLUTE, 3},
-	0xc0: {"CPY", IMMEDIATE, 2},
-	0xc4: {"CPY", ZERO, 2},
-	0xcc: {"CPY", ABSOLUTE, 3},
-	0x85: {"STA", ZERO, 2},
-	0x95: {"STA", ZERO_X, 2},
-	0x8d: {"STA", ABSOLUTE, 3},
-	0x9d: {"STA", ABSOLUTE_X, 3},
-	0x99: {"STA", ABSOLUTE_Y, 3},
-	0x81: {"STA", INDIRECT_X, 2},
-	0x91: {"STA", INDIRECT_Y, 2},
-	0xe6: {"INC", ZERO, 2},
-	0xf6: {"INC", ZERO_X, 2},
-	0xee: {"INC", ABSOLUTE, 3},
-	0xfe: {"INC", ABSOLUTE_X, 3},
-	0xc6: {"DEC", ZERO, 2},
-	0xd6: {"DEC", ZERO_X, 2},
-	0xce: {"DEC", ABSOLUTE, 3},
-	0xde: {"DEC", ABSOLUTE_X, 3},
-	0xaa: {"TAX", IMPLICIT, 1},
-	0x8a: {"TXA", IMPLICIT, 1},
-	0xca: {"DEX", IMPLICIT, 1},
-	0xe8: {"INX", IMPLICIT, 1},
-	0xa8: {"TAY", IMPLICIT, 1},
-	0x98: {"TYA", IMPLICIT, 1},
-	0x88: {"DEY", IMPLICIT, 1},
-	0xc8: {"INY", IMPLICIT, 1},
-	0x10: {"BPL", RELATIVE, 2},
-	0x30: {"BMI", RELATIVE, 2},
-	0x50: {"BVC", RELATIVE, 2},
-	0x70: {"BVS", RELATIVE, 2},
-	0x90: {"BCC", RELATIVE, 2},
-	0xb0: {"BCS", RELATIVE, 2},
-	0xf0: {"BEQ", RELATIVE, 2},
-	0xd0: {"BNE", RELATIVE, 2},
-	0x4c: {"JMP", ABSOLUTE, 3},
-	0x6c: {"JMP", INDIRECT, 3},
+	0x00: {"BRK", IMPLICIT, 1, 7},
+	0xea: {"NOP", IMPLICIT, 1, 2},
+	0x18: {"CLC", IMPLICIT, 1, 2},
+	0x38: {"SEC", IMPLICIT, 1, 2},
+	0x20: {"JSR", ABSOLUTE, 3, 6},
+	0x24: {"BIT", ZERO, 2, 3},
+	0x2c: {"BIT", ABSOLUTE, 3, 4},
+	0x60: {"RTS", IMPLICIT, 1, 6},
+	0x40: {"RTI", IMPLICIT, 1, 6},
+	0xa9: {"LDA", IMMEDIATE, 2, 2},
+	0xa5: {"LDA", ZERO, 2, 3},
+	0xb5: {"LDA", ZERO_X, 2, 4},
+	0xad: {"LDA", ABSOLUTE, 3, 4},
+	0xbd: {"LDA", ABSOLUTE_X, 3, 4},
+	0xb9: {"LDA", ABSOLUTE_Y, 3, 4},
+	0xa1: {"LDA", INDIRECT_X, 2, 6},
+	0xb1: {"LDA", INDIRECT_Y, 2, 5},
+	0xa2: {"LDX", IMMEDIATE, 2, 2},
+	0xa6: {"LDX", ZERO, 2, 3},
+	0xb6: {"LDX", ZERO_Y, 2, 4},
+	0xae: {"LDX", ABSOLUTE, 3, 4},
+	0xbe: {"LDX", ABSOLUTE_Y, 3, 4},
+	0xa0: {"LDY", IMMEDIATE, 2, 2},
+	0xa4: {"LDY", ZERO, 2, 3},
+	0xb4: {"LDY", ZERO_X, 2, 4},
+	0xac: {"LDY", ABSOLUTE, 3, 4},
+	0xbc: {"LDY", ABSOLUTE_X, 3, 4},
+	0x4a: {"LSR", ACCUMULATOR, 1, 2},
+	0x46: {"LSR", ZERO, 2, 5},
+	0x56: {"LSR", ZERO_X, 2, 6},
+	0x4e: {"LSR", ABSOLUTE, 3, 6},
+	0x5e: {"LSR", ABSOLUTE_X, 3, 7},
+	0x29: {"AND", IMMEDIATE, 2, 2},
+	0x25: {"AND", ZERO, 2, 3},
+	0x35: {"AND", ZERO_X, 2, 4},
+	0x2d: {"AND", ABSOLUTE, 3, 4},
+	0x3d: {"AND", ABSOLUTE_X, 3, 4},
+	0x39: {"AND", ABSOLUTE_Y, 3, 4},
+	0x21: {"AND", INDIRECT_X, 2, 6},
+	0x31: {"AND", INDIRECT_Y, 2, 5},
+	0x69: {"ADC", IMMEDIATE, 2, 2},
+	0x65: {"ADC", ZERO, 2, 3},
+	0x75: {"ADC", ZERO_X, 2, 4},
+	0x6d: {"ADC", ABSOLUTE, 3, 4},
+	0x7d: {"ADC", ABSOLUTE_X, 3, 4},
+	0x79: {"ADC", ABSOLUTE_Y, 3, 4},
+	0x61: {"ADC", INDIRECT_X, 2, 6},
+	0x71: {"ADC", INDIRECT_Y, 2, 5},
+	0xe9: {"SBC", IMMEDIATE, 2, 2},
+	0xe5: {"SBC", ZERO, 2, 3},
+	0xf5: {"SBC", ZERO_X, 2, 4},
+	0xed: {"SBC", ABSOLUTE, 3, 4},
+	0xfd: {"SBC", ABSOLUTE_X, 3, 4},
+	0xf9: {"SBC", ABSOLUTE_Y, 3, 4},
+	0xe1: {"SBC", INDIRECT_X, 2, 6},
+	0xf1: {"SBC", INDIRECT_Y, 2, 5},
+	0xc9: {"CMP", IMMEDIATE, 2, 2},
+	0xc5: {"CMP", ZERO, 2, 3},
+	0xd5: {"CMP", ZERO_X, 2, 4},
+	0xcd: {"CMP", ABSOLUTE, 3, 4},
+	0xdd: {"CMP", ABSOLUTE_X, 3, 4},
+	0xd9: {"CMP", ABSOLUTE_Y, 3, 4},
+	0xc1: {"CMP", INDIRECT_X, 2, 6},
+	0xd1: {"CMP", INDIRECT_Y, 2, 5},
+	0xe0: {"CPX", IMMEDIATE, 2, 2},
+	0xe4: {"CPX", ZERO, 2, 3},
+	0xec: {"CPX", ABSOLUTE, 3, 4},
+	0xc0: {"CPY", IMMEDIATE, 2, 2},
+	0xc4: {"CPY", ZERO, 2, 3},
+	0xcc: {"CPY", ABSOLUTE, 3, 4},
+	0x85: {"STA", ZERO, 2, 3},
+	0x95: {"STA", ZERO_X, 2, 4},
+	0x8d: {"STA", ABSOLUTE, 3, 4},
+	0x9d: {"STA", ABSOLUTE_X, 3, 5},
+	0x99: {"STA", ABSOLUTE_Y, 3, 5},
+	0x81: {"STA", INDIRECT_X, 2, 6},
+	0x91: {"STA", INDIRECT_Y, 2, 6},
+	0xe6: {"INC", ZERO, 2, 5},
+	0xf6: {"INC", ZERO_X, 2, 6},
+	0xee: {"INC", ABSOLUTE, 3, 6},
+	0xfe: {"INC", ABSOLUTE_X, 3, 7},
+	0xc6: {"DEC", ZERO, 2, 5},
+	0xd6: {"DEC", ZERO_X, 2, 6},
+	0xce: {"DEC", ABSOLUTE, 3, 6},
+	0xde: {"DEC", ABSOLUTE_X, 3, 7},
+	0xaa: {"TAX", IMPLICIT, 1, 2},
+	0x8a: {"TXA", IMPLICIT, 1, 2},
+	0xca: {"DEX", IMPLICIT, 1, 2},
+	0xe8: {"INX", IMPLICIT, 1, 2},
+	0xa8: {"TAY", IMPLICIT, 1, 2},
+	0x98: {"TYA", IMPLICIT, 1, 2},
+	0x88: {"DEY", IMPLICIT, 1, 2},
+	0xc8: {"INY", IMPLICIT, 1, 2},
+	0x10: {"BPL", RELATIVE, 2, 2},
+	0x30: {"BMI", RELATIVE, 2, 2},
+	0x50: {"BVC", RELATIVE, 2, 2},
+	0x70: {"BVS", RELATIVE, 2, 2},
+	0x90: {"BCC", RELATIVE, 2, 2},
+	0xb0: {"BCS", RELATIVE, 2, 2},
+	0xf0: {"BEQ", RELATIVE, 2, 2},
+	0xd0: {"BNE", RELATIVE, 2, 2},
+	0x4c: {"JMP", ABSOLUTE, 3, 3},
+	0x6c: {"JMP", INDIRECT, 3, 5},
 }
@@ -16,6 +16,13 @@ func TestDecode_NOP(t *testing.T) {
 	AssertNumberOfBytes(t, instr, 1)
 }
 
+func TestDecode_RTI(t *testing.T) {
+	instr := Decode(0x40)
+	AssertAction(t, instr, "RTI")
+	AssertAddressingMode(t, instr, IMPLICIT)
+	AssertNumberOfBytes(t, instr, 1)
+}
+
 func TestDecode_JMP(t *testing.T) {
 	t.Run("JMP Absolute", func(t *testing.T) {
 		instr := Decode(0x4c)
@@ -0,0 +1,57 @@
+package main
+
+// Decides which physical page of nametable memory each of the four logical
+// nametables (0x2000, 0x2400, 0x2800 and 0x2c00) uses.
+//
+// The wiring is up to the cartridge and some mappers rewire it at runtime, so the
+// ppu asks for every nametable access instead of caching the answer.
+// Pages 0 and 1 are the 2KB of vram inside the console. Pages 2 and 3 are the
+// extra 2KB that four-screen cartridges carry.
+//
+// See: https://www.nesdev.org/wiki/Mirroring#Nametable_Mirroring
+type NametableMapper interface {
+	NametablePage(table uint16) uint16
+}
+
+// The fixed mirroring modes selected by a solder pad or a simple mapper register.
+type MirroringMode int
+
+const (
+	// 0x2000 and 0x2400 share a page, 0x2800 and 0x2c00 share the other.
+	HORIZONTAL_MIRRORING MirroringMode = iota
+	// 0x2000 and 0x2800 share a page, 0x2400 and 0x2c00 share the other.
+	VERTICAL_MIRRORING
+	// All four nametables share the first page.
+	SINGLE_SCREEN_A
+	// All four nametables share the second page.
+	SINGLE_SCREEN_B
+	// Each nametable gets its own page.
+	FOUR_SCREEN
+)
+
+func (m MirroringMode) NametablePage(table uint16) uint16 {
+	switch m {
+	case VERTICAL_MIRRORING:
+		return table % 2
+	case SINGLE_SCREEN_A:
+		return 0
+	case SINGLE_SCREEN_B:
+		return 1
+	case FOUR_SCREEN:
+		return table
+	default:
+		return table / 2
+	}
+}
+
+// An arbitrary mapping for mappers like MMC5 and Namco 163 that can point each
+// nametable at any page. The mapper keeps a pointer to it and updates the entries
+// when the game writes to its mirroring registers.
+//
+// TODO(mjpatter88): MMC5 can also point a nametable at its ExRAM or fill mode. That
+// needs memory that lives on the cartridge, which isn't supported yet.
+type NametableBanks [4]uint16
+
+func (b *NametableBanks) NametablePage(table uint16) uint16 {
+	return b[table] % 4
+}
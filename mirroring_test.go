@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNametablePages(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapper NametableMapper
+		// Physical pages for 0x2000, 0x2400, 0x2800 and 0x2c00.
+		pages [4]uint16
+	}{
+		{"Horizontal", HORIZONTAL_MIRRORING, [4]uint16{0, 0, 1, 1}},
+		{"Vertical", VERTICAL_MIRRORING, [4]uint16{0, 1, 0, 1}},
+		{"Single screen A", SINGLE_SCREEN_A, [4]uint16{0, 0, 0, 0}},
+		{"Single screen B", SINGLE_SCREEN_B, [4]uint16{1, 1, 1, 1}},
+		{"Four screen", FOUR_SCREEN, [4]uint16{0, 1, 2, 3}},
+		{"Banks", &NametableBanks{1, 0, 3, 1}, [4]uint16{1, 0, 3, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := Ppu{Mirroring: test.mapper}
+			for table, page := range test.pages {
+				AssertNametablePage(t, &ppu, NAMETABLES_START+uint16(table)*0x0400, page)
+			}
+		})
+	}
+}
+
+func TestNametableMapperChanges(t *testing.T) {
+	t.Run("Defaults to horizontal", func(t *testing.T) {
+		ppu := Ppu{}
+		AssertNametablePage(t, &ppu, 0x2400, 0)
+		AssertNametablePage(t, &ppu, 0x2800, 1)
+	})
+
+	t.Run("Switching modes", func(t *testing.T) {
+		ppu := Ppu{Mirroring: VERTICAL_MIRRORING}
+		ppu.writeMemory(0x2405, 0x11)
+
+		ppu.Mirroring = SINGLE_SCREEN_B
+		AssertPpuMemoryValue(t, &ppu, 0x2005, 0x11)
+		AssertPpuMemoryValue(t, &ppu, 0x2c05, 0x11)
+	})
+
+	t.Run("Mapper updates its banks", func(t *testing.T) {
+		banks := NametableBanks{0, 0, 0, 0}
+		ppu := Ppu{Mirroring: &banks}
+		ppu.writeMemory(0x2005, 0x11)
+		AssertPpuMemoryValue(t, &ppu, 0x2c05, 0x11)
+
+		banks[3] = 1
+		AssertPpuMemoryValue(t, &ppu, 0x2c05, 0x00)
+	})
+
+	t.Run("Four screen doesn't mirror", func(t *testing.T) {
+		ppu := Ppu{Mirroring: FOUR_SCREEN}
+		for table := uint16(0); table < 4; table++ {
+			ppu.writeMemory(NAMETABLES_START+table*0x0400, uint8(table+1))
+		}
+		for table := uint16(0); table < 4; table++ {
+			AssertPpuMemoryValue(t, &ppu, NAMETABLES_START+table*0x0400, uint8(table+1))
+		}
+		// 0x3000 - 0x3eff still mirrors.
+		AssertPpuMemoryValue(t, &ppu, 0x3c00, 4)
+	})
+}
+
+// Test helpers
+func AssertNametablePage(t *testing.T, ppu *Ppu, address uint16, page uint16) {
+	actual := ppu.nametableIndex(address) / 0x0400
+	if actual != page {
+		t.Errorf("Expected %#x to resolve to page %d but was %d", address, page, actual)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+const (
+	PALETTE_COLORS = 64
+	// Each of the 8 combinations of PPUMASK emphasis bits gets its own set of colors.
+	PALETTE_SIZE = PALETTE_COLORS * 8
+)
+
+// How much each emphasis bit dims the other colors.
+//
+// See: https://www.nesdev.org/wiki/NTSC_video#Color_Tint_Bits
+const EMPHASIS_ATTENUATION = 0.746
+
+// Every color the ppu can output, indexed by emphasis<<6 | color where color is
+// the value stored in palette ram and emphasis is PPUMASK bits 5-7.
+type Palette [PALETTE_SIZE]color.RGBA
+
+func (p *Palette) Color(index uint8, emphasis uint8) color.RGBA {
+	return p[uint16(emphasis&0x07)<<6|uint16(index&0x3f)]
+}
+
+// Build a full palette from the 64 base colors. Emphasized colors are approximated
+// by dimming the channels that aren't emphasized.
+func NewPalette(colors [PALETTE_COLORS]color.RGBA) *Palette {
+	palette := Palette{}
+	for emphasis := 0; emphasis < 8; emphasis++ {
+		for i, c := range colors {
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+			// Bit 0 emphasizes red, bit 1 green and bit 2 blue.
+			if emphasis&0x01 != 0 {
+				g *= EMPHASIS_ATTENUATION
+				b *= EMPHASIS_ATTENUATION
+			}
+			if emphasis&0x02 != 0 {
+				r *= EMPHASIS_ATTENUATION
+				b *= EMPHASIS_ATTENUATION
+			}
+			if emphasis&0x04 != 0 {
+				r *= EMPHASIS_ATTENUATION
+				g *= EMPHASIS_ATTENUATION
+			}
+			palette[emphasis*PALETTE_COLORS+i] = color.RGBA{uint8(r), uint8(g), uint8(b), 0xFF}
+		}
+	}
+	return &palette
+}
+
+// Load a .pal file, which is a list of rgb triples with no header.
+// 64 color files get approximated emphasis. 512 color files already include it.
+//
+// See: https://www.nesdev.org/wiki/.pal
+func LoadPalette(r io.Reader) (*Palette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(data) {
+	case PALETTE_COLORS * 3:
+		colors := [PALETTE_COLORS]color.RGBA{}
+		for i := range colors {
+			colors[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF}
+		}
+		return NewPalette(colors), nil
+	case PALETTE_SIZE * 3:
+		palette := Palette{}
+		for i := range palette {
+			palette[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF}
+		}
+		return &palette, nil
+	default:
+		return nil, fmt.Errorf("palette should be %d or %d bytes but was %d", PALETTE_COLORS*3, PALETTE_SIZE*3, len(data))
+	}
+}
+
+// Knobs for generating a palette by decoding the ppu's video signal the way a
+// tv would. Tweaking these makes it possible to match captures from real hardware.
+type NtscParams struct {
+	// Rotation of the color wheel, in degrees.
+	Hue float64
+	// 1 is normal. 0 is greyscale.
+	Saturation float64
+	// 1 is normal.
+	Contrast float64
+	// 0 is normal. Added to every channel.
+	Brightness float64
+	// The gamma of the display the palette is meant for. 2.2 leaves the decoded
+	// colors alone.
+	Gamma float64
+}
+
+func DefaultNtscParams() NtscParams {
+	return NtscParams{Hue: 0, Saturation: 1, Contrast: 1, Brightness: 0, Gamma: 2.2}
+}
+
+// Signal voltages relative to sync for each luma level.
+//
+// See: https://www.nesdev.org/wiki/NTSC_video#Brightness_Levels
+var (
+	signalLow  = [4]float64{0.350, 0.518, 0.962, 1.550}
+	signalHigh = [4]float64{1.094, 1.506, 1.962, 1.962}
+)
+
+const (
+	SIGNAL_BLACK = 0.518
+	SIGNAL_WHITE = 1.962
+)
+
+// Generate all 512 colors by simulating the square wave the ppu outputs for
+// each one and decoding it into yiq and then rgb.
+//
+// See: https://www.nesdev.org/wiki/NTSC_video
+func GeneratePalette(params NtscParams) *Palette {
+	palette := Palette{}
+	for emphasis := 0; emphasis < 8; emphasis++ {
+		for i := 0; i < PALETTE_COLORS; i++ {
+			palette[emphasis*PALETTE_COLORS+i] = ntscColor(uint8(i), uint8(emphasis), params)
+		}
+	}
+	return &palette
+}
+
+func ntscColor(index uint8, emphasis uint8, params NtscParams) color.RGBA {
+	hue := int(index & 0x0f)
+	level := int(index>>4) & 0x03
+	// Columns 0xe and 0xf are always black.
+	if hue > 0x0d {
+		level = 1
+	}
+	low, high := signalLow[level], signalHigh[level]
+	// Column 0 is a flat grey and columns 0xd-0xf are flat darker greys.
+	if hue == 0x00 {
+		low = high
+	}
+	if hue > 0x0c {
+		high = low
+	}
+
+	// The wave spends half of each 12 phase cycle high and half low. The hue
+	// picks where in the cycle that happens.
+	inPhase := func(hue int, phase int) bool {
+		return (hue+phase)%12 < 6
+	}
+
+	var y, i, q float64
+	for phase := 0; phase < 12; phase++ {
+		signal := low
+		if inPhase(hue, phase) {
+			signal = high
+		}
+		// Each emphasis bit dims the part of the wave that lines up with its color.
+		if (emphasis&0x01 != 0 && inPhase(0x0c, phase)) ||
+			(emphasis&0x02 != 0 && inPhase(0x04, phase)) ||
+			(emphasis&0x04 != 0 && inPhase(0x08, phase)) {
+			signal *= EMPHASIS_ATTENUATION
+		}
+
+		value := (signal - SIGNAL_BLACK) / (SIGNAL_WHITE - SIGNAL_BLACK)
+		// Each phase is 30 degrees. The color burst is 4 phases ahead of the
+		// decoder's reference.
+		angle := math.Pi * (float64(phase) + 4 + params.Hue/30) / 6
+		y += value
+		i += value * math.Cos(angle)
+		q += value * math.Sin(angle)
+	}
+	// Demodulating only recovers half of the chroma amplitude, so i and q are doubled.
+	y = y/12*params.Contrast + params.Brightness
+	i = i / 6 * params.Saturation * params.Contrast
+	q = q / 6 * params.Saturation * params.Contrast
+
+	// FCC yiq to rgb.
+	r := y + 0.956*i + 0.621*q
+	g := y - 0.272*i - 0.647*q
+	b := y - 1.106*i + 1.703*q
+	return color.RGBA{gammaCorrect(r, params.Gamma), gammaCorrect(g, params.Gamma), gammaCorrect(b, params.Gamma), 0xFF}
+}
+
+// Convert a decoded channel to a byte for a display with the given gamma.
+// The signal is already meant for a 2.2 gamma tv.
+func gammaCorrect(value float64, gamma float64) uint8 {
+	if value <= 0 {
+		return 0
+	}
+	value = math.Pow(value, 2.2/gamma)
+	if value >= 1 {
+		return 0xFF
+	}
+	return uint8(value*255 + 0.5)
+}
+
+// The colors the ppu can output, indexed by the values stored in palette ram.
+// There's no single "correct" set of rgb values since the ppu outputs an analog
+// signal, but these are a commonly used approximation.
+//
+// See: https://www.nesdev.org/wiki/PPU_palettes
+var systemPalette = [PALETTE_COLORS]color.RGBA{
+	{0x80, 0x80, 0x80, 0xFF}, {0x00, 0x3D, 0xA6, 0xFF}, {0x00, 0x12, 0xB0, 0xFF}, {0x44, 0x00, 0x96, 0xFF},
+	{0xA1, 0x00, 0x5E, 0xFF}, {0xC7, 0x00, 0x28, 0xFF}, {0xBA, 0x06, 0x00, 0xFF}, {0x8C, 0x17, 0x00, 0xFF},
+	{0x5C, 0x2F, 0x00, 0xFF}, {0x10, 0x45, 0x00, 0xFF}, {0x05, 0x4A, 0x00, 0xFF}, {0x00, 0x47, 0x2E, 0xFF},
+	{0x00, 0x41, 0x66, 0xFF}, {0x00, 0x00, 0x00, 0xFF}, {0x05, 0x05, 0x05, 0xFF}, {0x05, 0x05, 0x05, 0xFF},
+	{0xC7, 0xC7, 0xC7, 0xFF}, {0x00, 0x77, 0xFF, 0xFF}, {0x21, 0x55, 0xFF, 0xFF}, {0x82, 0x37, 0xFA, 0xFF},
+	{0xEB, 0x2F, 0xB5, 0xFF}, {0xFF, 0x29, 0x50, 0xFF}, {0xFF, 0x22, 0x00, 0xFF}, {0xD6, 0x32, 0x00, 0xFF},
+	{0xC4, 0x62, 0x00, 0xFF}, {0x35, 0x80, 0x00, 0xFF}, {0x05, 0x8F, 0x00, 0xFF}, {0x00, 0x8A, 0x55, 0xFF},
+	{0x00, 0x99, 0xCC, 0xFF}, {0x21, 0x21, 0x21, 0xFF}, {0x09, 0x09, 0x09, 0xFF}, {0x09, 0x09, 0x09, 0xFF},
+	{0xFF, 0xFF, 0xFF, 0xFF}, {0x0F, 0xD7, 0xFF, 0xFF}, {0x69, 0xA2, 0xFF, 0xFF}, {0xD4, 0x80, 0xFF, 0xFF},
+	{0xFF, 0x45, 0xF3, 0xFF}, {0xFF, 0x61, 0x8B, 0xFF}, {0xFF, 0x88, 0x33, 0xFF}, {0xFF, 0x9C, 0x12, 0xFF},
+	{0xFA, 0xBC, 0x20, 0xFF}, {0x9F, 0xE3, 0x0E, 0xFF}, {0x2B, 0xF0, 0x35, 0xFF}, {0x0C, 0xF0, 0xA4, 0xFF},
+	{0x05, 0xFB, 0xFF, 0xFF}, {0x5E, 0x5E, 0x5E, 0xFF}, {0x0D, 0x0D, 0x0D, 0xFF}, {0x0D, 0x0D, 0x0D, 0xFF},
+	{0xFF, 0xFF, 0xFF, 0xFF}, {0xA6, 0xFC, 0xFF, 0xFF}, {0xB3, 0xEC, 0xFF, 0xFF}, {0xDA, 0xAB, 0xEB, 0xFF},
+	{0xFF, 0xA8, 0xF9, 0xFF}, {0xFF, 0xAB, 0xB3, 0xFF}, {0xFF, 0xD2, 0xB0, 0xFF}, {0xFF, 0xEF, 0xA6, 0xFF},
+	{0xFF, 0xF7, 0x9C, 0xFF}, {0xD7, 0xE8, 0x95, 0xFF}, {0xA6, 0xED, 0xAF, 0xFF}, {0xA2, 0xF2, 0xDA, 0xFF},
+	{0x99, 0xFF, 0xFC, 0xFF}, {0xDD, 0xDD, 0xDD, 0xFF}, {0x11, 0x11, 0x11, 0xFF}, {0x11, 0x11, 0x11, 0xFF},
+}
+
+// The palette to display frames with when no other one has been chosen.
+var defaultPalette = NewPalette(systemPalette)
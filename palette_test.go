@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestNewPalette(t *testing.T) {
+	palette := NewPalette(systemPalette)
+
+	t.Run("No emphasis", func(t *testing.T) {
+		for i := uint8(0); i < PALETTE_COLORS; i++ {
+			AssertColor(t, palette.Color(i, 0), systemPalette[i])
+		}
+	})
+
+	t.Run("Emphasis dims the other channels", func(t *testing.T) {
+		// 0x30 is white.
+		AssertColor(t, palette.Color(0x30, 0x01), color.RGBA{0xff, 0xbe, 0xbe, 0xff})
+		AssertColor(t, palette.Color(0x30, 0x02), color.RGBA{0xbe, 0xff, 0xbe, 0xff})
+		AssertColor(t, palette.Color(0x30, 0x04), color.RGBA{0xbe, 0xbe, 0xff, 0xff})
+		AssertColor(t, palette.Color(0x30, 0x07), color.RGBA{0x8d, 0x8d, 0x8d, 0xff})
+	})
+}
+
+func TestLoadPalette(t *testing.T) {
+	t.Run("64 colors", func(t *testing.T) {
+		data := make([]byte, PALETTE_COLORS*3)
+		data[0x16*3] = 0xff
+		data[0x16*3+1] = 0x20
+		data[0x16*3+2] = 0x10
+
+		palette, err := LoadPalette(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		AssertColor(t, palette.Color(0x16, 0), color.RGBA{0xff, 0x20, 0x10, 0xff})
+		// Emphasis is filled in.
+		AssertColor(t, palette.Color(0x16, 0x04), color.RGBA{0xbe, 0x17, 0x10, 0xff})
+	})
+
+	t.Run("512 colors", func(t *testing.T) {
+		data := make([]byte, PALETTE_SIZE*3)
+		index := 0x05<<6 | 0x16
+		data[index*3] = 0x11
+		data[index*3+1] = 0x22
+		data[index*3+2] = 0x33
+
+		palette, err := LoadPalette(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		AssertColor(t, palette.Color(0x16, 0x05), color.RGBA{0x11, 0x22, 0x33, 0xff})
+		AssertColor(t, palette.Color(0x16, 0x00), color.RGBA{0x00, 0x00, 0x00, 0xff})
+	})
+
+	t.Run("Wrong size", func(t *testing.T) {
+		_, err := LoadPalette(bytes.NewReader(make([]byte, 100)))
+		if err == nil {
+			t.Errorf("Expected an error but there was none")
+		}
+	})
+}
+
+func TestGeneratePalette(t *testing.T) {
+	palette := GeneratePalette(DefaultNtscParams())
+
+	t.Run("Greys", func(t *testing.T) {
+		for _, index := range []uint8{0x00, 0x10, 0x20, 0x30, 0x0d, 0x1d, 0x2d, 0x3d} {
+			c := palette.Color(index, 0)
+			if c.R != c.G || c.G != c.B {
+				t.Errorf("Expected %#x to be grey but was %v", index, c)
+			}
+		}
+		AssertColor(t, palette.Color(0x20, 0), color.RGBA{0xff, 0xff, 0xff, 0xff})
+	})
+
+	t.Run("Black columns", func(t *testing.T) {
+		for _, index := range []uint8{0x0e, 0x0f, 0x1e, 0x1f, 0x2e, 0x2f, 0x3e, 0x3f} {
+			AssertColor(t, palette.Color(index, 0), color.RGBA{0x00, 0x00, 0x00, 0xff})
+		}
+	})
+
+	t.Run("Hues", func(t *testing.T) {
+		AssertBrightestChannel(t, palette.Color(0x12, 0), "blue")
+		AssertBrightestChannel(t, palette.Color(0x16, 0), "red")
+		AssertBrightestChannel(t, palette.Color(0x1a, 0), "green")
+	})
+
+	t.Run("Emphasis", func(t *testing.T) {
+		AssertBrightestChannel(t, palette.Color(0x30, 0x01), "red")
+		AssertBrightestChannel(t, palette.Color(0x30, 0x02), "green")
+		AssertBrightestChannel(t, palette.Color(0x30, 0x04), "blue")
+	})
+
+	t.Run("No saturation", func(t *testing.T) {
+		params := DefaultNtscParams()
+		params.Saturation = 0
+		greys := GeneratePalette(params)
+
+		for i := uint8(0); i < PALETTE_COLORS; i++ {
+			c := greys.Color(i, 0)
+			if c.R != c.G || c.G != c.B {
+				t.Errorf("Expected %#x to be grey but was %v", i, c)
+			}
+		}
+	})
+
+	t.Run("Brightness", func(t *testing.T) {
+		params := DefaultNtscParams()
+		params.Brightness = 0.1
+		brighter := GeneratePalette(params)
+
+		if brighter.Color(0x00, 0).R <= palette.Color(0x00, 0).R {
+			t.Errorf("Expected %v to be brighter than %v", brighter.Color(0x00, 0), palette.Color(0x00, 0))
+		}
+	})
+
+	t.Run("Gamma", func(t *testing.T) {
+		params := DefaultNtscParams()
+		params.Gamma = 1.8
+		darker := GeneratePalette(params)
+
+		if darker.Color(0x00, 0).R >= palette.Color(0x00, 0).R {
+			t.Errorf("Expected %v to be darker than %v", darker.Color(0x00, 0), palette.Color(0x00, 0))
+		}
+	})
+
+	t.Run("Hue", func(t *testing.T) {
+		// Rotating back by 4 phases turns red into green.
+		params := DefaultNtscParams()
+		params.Hue = -120
+		rotated := GeneratePalette(params)
+
+		AssertColor(t, rotated.Color(0x16, 0), palette.Color(0x1a, 0))
+	})
+}
+
+// Test helpers
+func AssertColor(t *testing.T, actual color.RGBA, expected color.RGBA) {
+	if actual != expected {
+		t.Errorf("Expected color to be %v but was %v", expected, actual)
+	}
+}
+
+func AssertBrightestChannel(t *testing.T, c color.RGBA, channel string) {
+	var actual string
+	switch {
+	case c.R > c.G && c.R > c.B:
+		actual = "red"
+	case c.G > c.R && c.G > c.B:
+		actual = "green"
+	case c.B > c.R && c.B > c.G:
+		actual = "blue"
+	}
+	if actual != channel {
+		t.Errorf("Expected %v to be mostly %s but was %s", c, channel, actual)
+	}
+}
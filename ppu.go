@@ -0,0 +1,337 @@
+package main
+
+// PPU Registers (as seen by the cpu)
+// https://www.nesdev.org/wiki/PPU_registers
+const (
+	PPUCTRL   = 0x2000
+	PPUMASK   = 0x2001
+	PPUSTATUS = 0x2002
+	OAMADDR   = 0x2003
+	OAMDATA   = 0x2004
+	PPUSCROLL = 0x2005
+	PPUADDR   = 0x2006
+	PPUDATA   = 0x2007
+)
+
+// PPUCTRL flags
+const (
+	CTRL_NAMETABLE        = 0x03
+	CTRL_VRAM_INCREMENT   = 1 << 2
+	CTRL_SPRITE_TABLE     = 1 << 3
+	CTRL_BACKGROUND_TABLE = 1 << 4
+	CTRL_SPRITE_SIZE      = 1 << 5
+	CTRL_GENERATE_NMI     = 1 << 7
+)
+
+// PPUMASK flags
+const (
+	MASK_GREYSCALE            = 1 << 0
+	MASK_SHOW_BACKGROUND_LEFT = 1 << 1
+	MASK_SHOW_SPRITES_LEFT    = 1 << 2
+	MASK_SHOW_BACKGROUND      = 1 << 3
+	MASK_SHOW_SPRITES         = 1 << 4
+	// Red on NTSC, green on PAL.
+	MASK_EMPHASIZE_RED = 1 << 5
+	// Green on NTSC, red on PAL.
+	MASK_EMPHASIZE_GREEN = 1 << 6
+	MASK_EMPHASIZE_BLUE  = 1 << 7
+)
+
+// PPUSTATUS flags
+const (
+	STATUS_SPRITE_OVERFLOW = 1 << 5
+	STATUS_SPRITE_ZERO_HIT = 1 << 6
+	STATUS_VBLANK          = 1 << 7
+)
+
+// TV system the console was built for.
+// TODO(mjpatter88): PAL timing isn't supported yet. Only the emphasis bits differ.
+const (
+	REGION_NTSC = iota
+	REGION_PAL
+)
+
+// PPU Memory Addresses
+const (
+	PATTERN_TABLES_END    = 0x1fff
+	NAMETABLES_START      = 0x2000
+	NAMETABLES_END        = 0x3eff
+	PALETTE_TABLE_START   = 0x3f00
+	PPU_ADDRESS_SPACE_END = 0x3fff
+)
+
+// Timing
+const (
+	DOTS_PER_SCANLINE   = 341
+	SCANLINES_PER_FRAME = 262
+	VISIBLE_SCANLINES   = 240
+	VBLANK_SCANLINE     = 241
+	PRE_RENDER_SCANLINE = 261
+)
+
+type Ppu struct {
+	ctrl    uint8
+	mask    uint8
+	status  uint8
+	oamAddr uint8
+	oamData [256]uint8
+
+	// Internal registers used for both scrolling and vram access.
+	// v is the current vram address and t is the address of the top left of the
+	// screen. Both are 15 bits:
+	// yyy NN YYYYY XXXXX
+	// fine y, nametable, coarse y, coarse x
+	// PPUSCROLL and PPUADDR both take two writes and share a single toggle
+	// to track which write is next. Reading PPUSTATUS resets it.
+	//
+	// See: https://www.nesdev.org/wiki/PPU_scrolling
+	v           uint16
+	t           uint16
+	fineX       uint8
+	writeToggle bool
+
+	// PPUDATA reads outside of palette memory return the contents of this buffer
+	// and then refill it, so the first read after setting PPUADDR is stale.
+	readBuffer uint8
+
+	// Writing to any register fills this latch. Reading a write-only register
+	// returns whatever was left in it.
+	latch uint8
+
+	// TODO(mjpatter88): the pattern tables live on the cartridge. Treat them as
+	// CHR RAM until roms are supported.
+	chrRam [0x2000]uint8
+	// The first 2KB is the console's vram. The second 2KB stands in for the extra
+	// vram on four-screen cartridges.
+	// TODO(mjpatter88): move the extra vram to the cartridge once roms are supported.
+	vram         [0x1000]uint8
+	paletteTable [32]uint8
+	// Set by the cartridge. Defaults to horizontal mirroring when nil.
+	Mirroring NametableMapper
+
+	Scanline    int
+	Dot         int
+	Frame       uint64
+	FrameBuffer FrameBuffer
+	Region      int
+
+	// Background fetches for the next tile.
+	nametableByte uint8
+	attributeByte uint8
+	patternLow    uint8
+	patternHigh   uint8
+	// The next 16 background pixels, 4 bits each: 2 bits of palette and 2 bits of pattern.
+	tileData uint64
+	// Sprites selected for the next scanline.
+	lineSprites []sprite
+
+	nmiInterrupt bool
+}
+
+func (p *Ppu) ReadRegister(address uint16) uint8 {
+	switch address {
+	case PPUSTATUS:
+		// Only the top three bits are driven, the rest come from the latch.
+		value := (p.status & 0xe0) | (p.latch & 0x1f)
+		p.status &^= STATUS_VBLANK
+		p.writeToggle = false
+		p.latch = value
+	case OAMDATA:
+		p.latch = p.oamData[p.oamAddr]
+	case PPUDATA:
+		p.latch = p.readData()
+	}
+	// PPUCTRL, PPUMASK, OAMADDR, PPUSCROLL and PPUADDR are write-only.
+	return p.latch
+}
+
+func (p *Ppu) WriteRegister(address uint16, value uint8) {
+	p.latch = value
+	switch address {
+	case PPUCTRL:
+		// Enabling nmi during vblank immediately generates one.
+		nmiWasEnabled := p.ctrl&CTRL_GENERATE_NMI != 0
+		p.ctrl = value
+		p.t = (p.t & 0xf3ff) | (uint16(value&CTRL_NAMETABLE) << 10)
+		if !nmiWasEnabled && p.ctrl&CTRL_GENERATE_NMI != 0 && p.status&STATUS_VBLANK != 0 {
+			p.nmiInterrupt = true
+		}
+	case PPUMASK:
+		p.mask = value
+	case PPUSTATUS:
+		// Read-only
+	case OAMADDR:
+		p.oamAddr = value
+	case OAMDATA:
+		p.oamData[p.oamAddr] = value
+		p.oamAddr++
+	case PPUSCROLL:
+		if !p.writeToggle {
+			// Coarse x goes into t and fine x gets its own register.
+			p.t = (p.t & 0xffe0) | uint16(value>>3)
+			p.fineX = value & 0x07
+		} else {
+			// Both coarse and fine y go into t.
+			p.t = (p.t & 0x8c1f) | (uint16(value&0x07) << 12) | (uint16(value&0xf8) << 2)
+		}
+		p.writeToggle = !p.writeToggle
+	case PPUADDR:
+		// High byte first, then low byte. The address space is only 14 bits.
+		// The new address doesn't take effect until the second write copies t into v.
+		if !p.writeToggle {
+			p.t = (p.t & 0x00ff) | (uint16(value&0x3f) << 8)
+		} else {
+			p.t = (p.t & 0xff00) | uint16(value)
+			p.v = p.t
+		}
+		p.writeToggle = !p.writeToggle
+	case PPUDATA:
+		p.writeMemory(p.v, value)
+		p.incrementVramAddr()
+	}
+}
+
+func (p *Ppu) readData() uint8 {
+	address := p.v & PPU_ADDRESS_SPACE_END
+	p.incrementVramAddr()
+
+	// Palette reads aren't buffered, but the buffer still gets refilled with
+	// the nametable byte "underneath" the palette.
+	if address >= PALETTE_TABLE_START {
+		p.readBuffer = p.readMemory(address - 0x1000)
+		return p.readMemory(address)
+	}
+
+	value := p.readBuffer
+	p.readBuffer = p.readMemory(address)
+	return value
+}
+
+func (p *Ppu) incrementVramAddr() {
+	// While rendering, v is busy being used for fetches. Accessing PPUDATA
+	// bumps both coarse x and y instead of doing a normal increment.
+	if p.renderingEnabled() && (p.Scanline < VISIBLE_SCANLINES || p.Scanline == PRE_RENDER_SCANLINE) {
+		p.incrementX()
+		p.incrementY()
+		return
+	}
+
+	if p.ctrl&CTRL_VRAM_INCREMENT != 0 {
+		p.v += 32
+	} else {
+		p.v += 1
+	}
+	p.v &= 0x7fff
+}
+
+func (p *Ppu) readMemory(address uint16) uint8 {
+	address &= PPU_ADDRESS_SPACE_END
+	switch {
+	case address <= PATTERN_TABLES_END:
+		return p.chrRam[address]
+	case address <= NAMETABLES_END:
+		return p.vram[p.nametableIndex(address)]
+	default:
+		color := p.paletteTable[paletteIndex(address)]
+		// Greyscale keeps only the brightness, leaving the grey at the start of the row.
+		// It applies to PPUDATA reads as well as rendering.
+		if p.mask&MASK_GREYSCALE != 0 {
+			color &= 0x30
+		}
+		return color
+	}
+}
+
+func (p *Ppu) writeMemory(address uint16, value uint8) {
+	address &= PPU_ADDRESS_SPACE_END
+	switch {
+	case address <= PATTERN_TABLES_END:
+		p.chrRam[address] = value
+	case address <= NAMETABLES_END:
+		p.vram[p.nametableIndex(address)] = value
+	default:
+		p.paletteTable[paletteIndex(address)] = value
+	}
+}
+
+// Map a nametable address (0x2000 - 0x3eff) to an index into vram.
+//
+// There are four logical nametables but usually only enough vram for two of them.
+// The cartridge decides which page each one uses.
+//
+// See: https://www.nesdev.org/wiki/Mirroring#Nametable_Mirroring
+func (p *Ppu) nametableIndex(address uint16) uint16 {
+	// 0x3000 - 0x3eff mirrors 0x2000 - 0x2eff
+	offset := (address - NAMETABLES_START) & 0x0fff
+	table := offset / 0x0400
+
+	var mapper NametableMapper = HORIZONTAL_MIRRORING
+	if p.Mirroring != nil {
+		mapper = p.Mirroring
+	}
+	page := mapper.NametablePage(table)
+	return page*0x0400 + offset%0x0400
+}
+
+// Map a palette address (0x3f00 - 0x3fff) to an index into palette ram.
+//
+// Palette ram is 32 bytes mirrored through the whole range. On top of that,
+// 0x3f10, 0x3f14, 0x3f18 and 0x3f1c are mirrors of 0x3f00, 0x3f04, 0x3f08 and 0x3f0c.
+func paletteIndex(address uint16) uint16 {
+	index := address & 0x1f
+	if index >= 0x10 && index%4 == 0 {
+		index -= 0x10
+	}
+	return index
+}
+
+// Advance the ppu by the given number of dots (ppu cycles).
+// The ppu runs 3 dots for every cpu cycle.
+func (p *Ppu) Tick(dots int) {
+	for i := 0; i < dots; i++ {
+		p.nextDot()
+		p.render()
+
+		switch {
+		case p.Scanline == VBLANK_SCANLINE && p.Dot == 1:
+			p.status |= STATUS_VBLANK
+			if p.ctrl&CTRL_GENERATE_NMI != 0 {
+				p.nmiInterrupt = true
+			}
+		case p.Scanline == PRE_RENDER_SCANLINE && p.Dot == 1:
+			p.status &^= STATUS_VBLANK | STATUS_SPRITE_ZERO_HIT | STATUS_SPRITE_OVERFLOW
+		}
+	}
+}
+
+func (p *Ppu) nextDot() {
+	// When rendering is enabled, odd frames skip the last dot of the pre-render
+	// line and jump straight to the start of the next frame.
+	if p.renderingEnabled() && p.Frame%2 == 1 && p.Scanline == PRE_RENDER_SCANLINE && p.Dot == DOTS_PER_SCANLINE-2 {
+		p.Dot = 0
+		p.Scanline = 0
+		p.Frame++
+		return
+	}
+
+	p.Dot++
+	if p.Dot == DOTS_PER_SCANLINE {
+		p.Dot = 0
+		p.Scanline++
+		if p.Scanline == SCANLINES_PER_FRAME {
+			p.Scanline = 0
+			p.Frame++
+		}
+	}
+}
+
+func (p *Ppu) renderingEnabled() bool {
+	return p.mask&(MASK_SHOW_BACKGROUND|MASK_SHOW_SPRITES) != 0
+}
+
+// Returns true if the ppu has raised an nmi since the last time this was called.
+func (p *Ppu) PollNmi() bool {
+	nmi := p.nmiInterrupt
+	p.nmiInterrupt = false
+	return nmi
+}
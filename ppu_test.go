@@ -0,0 +1,392 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPpuAddr(t *testing.T) {
+	t.Run("High byte then low byte", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUADDR, 0x21)
+		ppu.WriteRegister(PPUADDR, 0x08)
+
+		AssertVramAddr(t, &ppu, 0x2108)
+	})
+
+	t.Run("Address is 14 bits", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUADDR, 0xff)
+		ppu.WriteRegister(PPUADDR, 0xff)
+
+		AssertVramAddr(t, &ppu, 0x3fff)
+	})
+
+	t.Run("Reading status resets the write toggle", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUADDR, 0x21)
+		ppu.ReadRegister(PPUSTATUS)
+		ppu.WriteRegister(PPUADDR, 0x23)
+		ppu.WriteRegister(PPUADDR, 0x05)
+
+		AssertVramAddr(t, &ppu, 0x2305)
+	})
+
+	t.Run("Toggle is shared with PPUSCROLL", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUSCROLL, 0x10)
+		ppu.WriteRegister(PPUADDR, 0x05)
+
+		// The second write of the pair is the low byte.
+		AssertVramAddr(t, &ppu, 0x0005)
+	})
+}
+
+func TestPpuScroll(t *testing.T) {
+	t.Run("X then Y", func(t *testing.T) {
+		ppu := Ppu{}
+		// x = 125 (coarse 15, fine 5), y = 94 (coarse 11, fine 6)
+		ppu.WriteRegister(PPUSCROLL, 0x7d)
+		ppu.WriteRegister(PPUSCROLL, 0x5e)
+
+		AssertTempAddr(t, &ppu, 0x616f)
+		AssertFineX(t, &ppu, 5)
+	})
+
+	t.Run("Doesn't change v", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUSCROLL, 0x7d)
+		ppu.WriteRegister(PPUSCROLL, 0x5e)
+
+		AssertVramAddr(t, &ppu, 0x0000)
+	})
+
+	t.Run("PPUCTRL sets the nametable", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUSCROLL, 0x7d)
+		ppu.WriteRegister(PPUCTRL, 0x03)
+
+		AssertTempAddr(t, &ppu, 0x0c0f)
+	})
+
+	t.Run("PPUADDR and PPUSCROLL share t", func(t *testing.T) {
+		// The example from https://www.nesdev.org/wiki/PPU_scrolling#Summary
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUCTRL, 0x00)
+		ppu.ReadRegister(PPUSTATUS)
+		ppu.WriteRegister(PPUSCROLL, 0x7d)
+		ppu.WriteRegister(PPUSCROLL, 0x5e)
+		ppu.WriteRegister(PPUADDR, 0x3d)
+		AssertTempAddr(t, &ppu, 0x3d6f)
+
+		ppu.WriteRegister(PPUADDR, 0xf0)
+		AssertTempAddr(t, &ppu, 0x3df0)
+		AssertVramAddr(t, &ppu, 0x3df0)
+		AssertFineX(t, &ppu, 5)
+	})
+}
+
+func TestPpuData(t *testing.T) {
+	t.Run("Write", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUADDR, 0x23)
+		ppu.WriteRegister(PPUADDR, 0x05)
+		ppu.WriteRegister(PPUDATA, 0x66)
+
+		AssertPpuMemoryValue(t, &ppu, 0x2305, 0x66)
+		AssertVramAddr(t, &ppu, 0x2306)
+	})
+
+	t.Run("Reads are buffered", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.vram[0x0305] = 0x66
+		ppu.vram[0x0306] = 0x77
+		ppu.WriteRegister(PPUADDR, 0x23)
+		ppu.WriteRegister(PPUADDR, 0x05)
+
+		// The first read returns the stale buffer contents.
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x00)
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x66)
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x77)
+	})
+
+	t.Run("Pattern table reads are buffered", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.chrRam[0x1234] = 0x66
+		ppu.WriteRegister(PPUADDR, 0x12)
+		ppu.WriteRegister(PPUADDR, 0x34)
+
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x00)
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x66)
+	})
+
+	t.Run("Palette reads are not buffered", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.paletteTable[0x01] = 0x2a
+		ppu.vram[0x0701] = 0x66
+		ppu.WriteRegister(PPUADDR, 0x3f)
+		ppu.WriteRegister(PPUADDR, 0x01)
+
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x2a)
+		// The buffer picks up the nametable byte underneath the palette (0x2f01).
+		if ppu.readBuffer != 0x66 {
+			t.Errorf("Expected read buffer to be %#x but was %#x", 0x66, ppu.readBuffer)
+		}
+	})
+
+	t.Run("Greyscale palette reads", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.paletteTable[0x01] = 0x2a
+		ppu.WriteRegister(PPUMASK, MASK_GREYSCALE)
+		ppu.WriteRegister(PPUADDR, 0x3f)
+		ppu.WriteRegister(PPUADDR, 0x01)
+
+		AssertRegisterRead(t, &ppu, PPUDATA, 0x20)
+	})
+
+	t.Run("Increment by 32", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUCTRL, CTRL_VRAM_INCREMENT)
+		ppu.WriteRegister(PPUADDR, 0x20)
+		ppu.WriteRegister(PPUADDR, 0x00)
+		ppu.WriteRegister(PPUDATA, 0x66)
+		ppu.WriteRegister(PPUDATA, 0x77)
+
+		AssertPpuMemoryValue(t, &ppu, 0x2000, 0x66)
+		AssertPpuMemoryValue(t, &ppu, 0x2020, 0x77)
+		AssertVramAddr(t, &ppu, 0x2040)
+	})
+}
+
+func TestPpuStatus(t *testing.T) {
+	t.Run("Reading clears vblank", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.status = STATUS_VBLANK | STATUS_SPRITE_ZERO_HIT
+
+		AssertRegisterRead(t, &ppu, PPUSTATUS, STATUS_VBLANK|STATUS_SPRITE_ZERO_HIT)
+		AssertRegisterRead(t, &ppu, PPUSTATUS, STATUS_SPRITE_ZERO_HIT)
+	})
+
+	t.Run("Low bits come from the latch", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.status = STATUS_VBLANK
+		ppu.WriteRegister(PPUMASK, 0x1e)
+
+		AssertRegisterRead(t, &ppu, PPUSTATUS, STATUS_VBLANK|0x1e)
+	})
+}
+
+func TestPpuWriteOnlyRegisters(t *testing.T) {
+	ppu := Ppu{}
+	ppu.WriteRegister(PPUCTRL, 0x01)
+	ppu.WriteRegister(PPUMASK, 0x1e)
+
+	AssertRegisterRead(t, &ppu, PPUCTRL, 0x1e)
+	AssertRegisterRead(t, &ppu, PPUADDR, 0x1e)
+}
+
+func TestOamData(t *testing.T) {
+	ppu := Ppu{}
+	ppu.WriteRegister(OAMADDR, 0x10)
+	ppu.WriteRegister(OAMDATA, 0x66)
+	ppu.WriteRegister(OAMDATA, 0x77)
+
+	if ppu.oamData[0x10] != 0x66 || ppu.oamData[0x11] != 0x77 {
+		t.Errorf("Expected oam data to be [0x66 0x77] but was %#x", ppu.oamData[0x10:0x12])
+	}
+
+	// Reads don't increment the address.
+	ppu.WriteRegister(OAMADDR, 0x11)
+	AssertRegisterRead(t, &ppu, OAMDATA, 0x77)
+	AssertRegisterRead(t, &ppu, OAMDATA, 0x77)
+}
+
+func TestPaletteMirroring(t *testing.T) {
+	t.Run("Background color mirrors", func(t *testing.T) {
+		for _, address := range []uint16{0x3f10, 0x3f14, 0x3f18, 0x3f1c} {
+			ppu := Ppu{}
+			ppu.writeMemory(address, 0x2a)
+
+			AssertPpuMemoryValue(t, &ppu, address-0x10, 0x2a)
+		}
+	})
+
+	t.Run("Sprite colors don't mirror", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.writeMemory(0x3f11, 0x2a)
+
+		AssertPpuMemoryValue(t, &ppu, 0x3f01, 0x00)
+	})
+
+	t.Run("Mirrored through 0x3fff", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.writeMemory(0x3f05, 0x2a)
+
+		AssertPpuMemoryValue(t, &ppu, 0x3f25, 0x2a)
+		AssertPpuMemoryValue(t, &ppu, 0x3fe5, 0x2a)
+	})
+}
+
+func TestNametableMirroring(t *testing.T) {
+	t.Run("Horizontal", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Mirroring = HORIZONTAL_MIRRORING
+		ppu.writeMemory(0x2005, 0x11)
+		ppu.writeMemory(0x2805, 0x22)
+
+		AssertPpuMemoryValue(t, &ppu, 0x2405, 0x11)
+		AssertPpuMemoryValue(t, &ppu, 0x2c05, 0x22)
+	})
+
+	t.Run("Vertical", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Mirroring = VERTICAL_MIRRORING
+		ppu.writeMemory(0x2005, 0x11)
+		ppu.writeMemory(0x2405, 0x22)
+
+		AssertPpuMemoryValue(t, &ppu, 0x2805, 0x11)
+		AssertPpuMemoryValue(t, &ppu, 0x2c05, 0x22)
+	})
+
+	t.Run("0x3000 mirrors 0x2000", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.writeMemory(0x2123, 0x11)
+
+		AssertPpuMemoryValue(t, &ppu, 0x3123, 0x11)
+	})
+}
+
+func TestVblank(t *testing.T) {
+	// vblank starts on dot 1 of scanline 241.
+	dotsToVblank := VBLANK_SCANLINE*DOTS_PER_SCANLINE + 1
+
+	t.Run("Sets vblank", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Tick(dotsToVblank - 1)
+		AssertVblank(t, &ppu, false)
+
+		ppu.Tick(1)
+		AssertVblank(t, &ppu, true)
+	})
+
+	t.Run("Clears vblank on the pre-render scanline", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Tick(PRE_RENDER_SCANLINE*DOTS_PER_SCANLINE + 1)
+
+		AssertVblank(t, &ppu, false)
+	})
+
+	t.Run("Generates nmi", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.WriteRegister(PPUCTRL, CTRL_GENERATE_NMI)
+		ppu.Tick(dotsToVblank)
+
+		if !ppu.PollNmi() {
+			t.Errorf("Expected nmi but there was none")
+		}
+		if ppu.PollNmi() {
+			t.Errorf("Expected nmi to be cleared after polling")
+		}
+	})
+
+	t.Run("No nmi when disabled", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Tick(dotsToVblank)
+
+		if ppu.PollNmi() {
+			t.Errorf("Expected no nmi")
+		}
+	})
+
+	t.Run("Enabling nmi during vblank", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Tick(dotsToVblank)
+		ppu.WriteRegister(PPUCTRL, CTRL_GENERATE_NMI)
+
+		if !ppu.PollNmi() {
+			t.Errorf("Expected nmi but there was none")
+		}
+	})
+
+	t.Run("Wraps to the next frame", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.Tick(SCANLINES_PER_FRAME * DOTS_PER_SCANLINE)
+
+		if ppu.Scanline != 0 || ppu.Dot != 0 || ppu.Frame != 1 {
+			t.Errorf("Expected frame 1 scanline 0 dot 0 but was frame %d scanline %d dot %d", ppu.Frame, ppu.Scanline, ppu.Dot)
+		}
+	})
+}
+
+func TestOddFrames(t *testing.T) {
+	// Count the dots from the start of one frame to the start of the next.
+	frameLength := func(ppu *Ppu) int {
+		frame := ppu.Frame
+		dots := 0
+		for ppu.Frame == frame {
+			ppu.Tick(1)
+			dots++
+		}
+		return dots
+	}
+
+	t.Run("Odd frames are one dot shorter while rendering", func(t *testing.T) {
+		ppu := Ppu{}
+		ppu.mask = MASK_SHOW_BACKGROUND
+
+		if dots := frameLength(&ppu); dots != 89342 {
+			t.Errorf("Expected even frame to be 89342 dots but was %d", dots)
+		}
+		if dots := frameLength(&ppu); dots != 89341 {
+			t.Errorf("Expected odd frame to be 89341 dots but was %d", dots)
+		}
+	})
+
+	t.Run("No skipped dot when rendering is disabled", func(t *testing.T) {
+		ppu := Ppu{}
+		frameLength(&ppu)
+
+		if dots := frameLength(&ppu); dots != 89342 {
+			t.Errorf("Expected odd frame to be 89342 dots but was %d", dots)
+		}
+	})
+}
+
+// Test helpers
+func AssertVramAddr(t *testing.T, ppu *Ppu, value uint16) {
+	if ppu.v != value {
+		t.Errorf("Expected vram address to be %#x but was %#x", value, ppu.v)
+	}
+}
+
+func AssertTempAddr(t *testing.T, ppu *Ppu, value uint16) {
+	if ppu.t != value {
+		t.Errorf("Expected temp vram address to be %#x but was %#x", value, ppu.t)
+	}
+}
+
+func AssertFineX(t *testing.T, ppu *Ppu, value uint8) {
+	if ppu.fineX != value {
+		t.Errorf("Expected fine x to be %d but was %d", value, ppu.fineX)
+	}
+}
+
+func AssertPpuMemoryValue(t *testing.T, ppu *Ppu, address uint16, value uint8) {
+	actual := ppu.readMemory(address)
+	if actual != value {
+		t.Errorf("Expected ppu memory value at %#x to be %#x but was %#x", address, value, actual)
+	}
+}
+
+func AssertRegisterRead(t *testing.T, ppu *Ppu, address uint16, value uint8) {
+	actual := ppu.ReadRegister(address)
+	if actual != value {
+		t.Errorf("Expected read of %#x to be %#x but was %#x", address, value, actual)
+	}
+}
+
+func AssertVblank(t *testing.T, ppu *Ppu, status bool) {
+	actual := ppu.status&STATUS_VBLANK != 0
+	if actual != status {
+		t.Errorf("Expected vblank status to be %t but was %t", status, actual)
+	}
+}
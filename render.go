@@ -0,0 +1,377 @@
+package main
+
+// Attribute tables sit at the end of each nametable.
+const ATTRIBUTE_TABLE_OFFSET = 0x03c0
+
+// Sprite attribute flags
+const (
+	SPRITE_PALETTE         = 0x03
+	SPRITE_BEHIND          = 1 << 5
+	SPRITE_FLIP_HORIZONTAL = 1 << 6
+	SPRITE_FLIP_VERTICAL   = 1 << 7
+)
+
+const MAX_SPRITES_PER_SCANLINE = 8
+
+// Dots where the first two background tiles of the next line are fetched.
+const (
+	PREFETCH_START = 321
+	PREFETCH_END   = 336
+)
+
+// Dots where sprite patterns for the next line are fetched, 8 dots per sprite.
+const (
+	SPRITE_FETCH_START = 257
+	SPRITE_FETCH_END   = 320
+)
+
+// Dots on the pre-render line where the vertical scroll is reloaded from t.
+const (
+	COPY_Y_START = 280
+	COPY_Y_END   = 304
+)
+
+// An entry in oam.
+//
+// See: https://www.nesdev.org/wiki/PPU_OAM
+type sprite struct {
+	index      int
+	y          uint8
+	tile       uint8
+	attributes uint8
+	x          uint8
+	// The row of the pattern being drawn, fetched after the sprite is selected.
+	patternLow  uint8
+	patternHigh uint8
+}
+
+// Run the rendering pipeline for the current dot.
+//
+// Each visible dot outputs one pixel. Meanwhile the ppu fetches the tile two tiles
+// ahead of the one being drawn, 8 dots per tile, and scrolls by incrementing v.
+// The end of each line selects and fetches the sprites for the next one.
+//
+// See: https://www.nesdev.org/wiki/PPU_rendering
+// and the timing diagram: https://www.nesdev.org/w/images/default/4/4f/Ppu.svg
+func (p *Ppu) render() {
+	visibleLine := p.Scanline < VISIBLE_SCANLINES
+	renderLine := visibleLine || p.Scanline == PRE_RENDER_SCANLINE
+	visibleDot := p.Dot >= 1 && p.Dot <= FRAME_WIDTH
+	prefetchDot := p.Dot >= PREFETCH_START && p.Dot <= PREFETCH_END
+
+	if visibleLine && visibleDot {
+		p.renderPixel(p.Dot-1, p.Scanline)
+	}
+	if !p.renderingEnabled() || !renderLine {
+		return
+	}
+
+	if visibleDot || prefetchDot {
+		p.tileData <<= 4
+		switch p.Dot % 8 {
+		case 1:
+			p.fetchNametableByte()
+		case 3:
+			p.fetchAttributeByte()
+		case 5:
+			p.fetchPatternLow()
+		case 7:
+			p.fetchPatternHigh()
+		case 0:
+			p.storeTileData()
+			p.incrementX()
+		}
+	}
+
+	if p.Dot == FRAME_WIDTH {
+		p.incrementY()
+	}
+	if p.Dot == SPRITE_FETCH_START {
+		p.copyX()
+		p.lineSprites = p.evaluateSprites((p.Scanline + 1) % SCANLINES_PER_FRAME)
+	}
+	if p.Dot >= SPRITE_FETCH_START && p.Dot <= SPRITE_FETCH_END {
+		offset := p.Dot - SPRITE_FETCH_START
+		p.fetchSpritePattern(offset/8, offset%8)
+	}
+	if p.Scanline == PRE_RENDER_SCANLINE && p.Dot >= COPY_Y_START && p.Dot <= COPY_Y_END {
+		p.copyY()
+	}
+}
+
+func (p *Ppu) renderPixel(x int, y int) {
+	var background uint8
+	if p.showBackground(x) {
+		background = p.backgroundPixel()
+	}
+
+	var spriteColor uint8
+	var behind, sprite0 bool
+	if p.showSprites(x) {
+		spriteColor, behind, sprite0 = p.spritePixel(x)
+	}
+
+	// The hit never happens on the last pixel.
+	if sprite0 && background != 0 && x != FRAME_WIDTH-1 {
+		p.status |= STATUS_SPRITE_ZERO_HIT
+	}
+
+	color := background
+	if spriteColor != 0 && (background == 0 || !behind) {
+		color = spriteColor
+	}
+	p.FrameBuffer.SetPixel(x, y, p.paletteColor(color), p.emphasis())
+}
+
+func (p *Ppu) showBackground(x int) bool {
+	if p.mask&MASK_SHOW_BACKGROUND == 0 {
+		return false
+	}
+	return x >= 8 || p.mask&MASK_SHOW_BACKGROUND_LEFT != 0
+}
+
+// Returns the index into palette ram of the background pixel being drawn.
+// An index of 0 means the pixel is transparent.
+func (p *Ppu) backgroundPixel() uint8 {
+	// The tile being drawn is in the top 32 bits. Fine x picks the pixel within it.
+	data := uint32(p.tileData>>32) >> ((7 - p.fineX) * 4)
+	pixel := uint8(data & 0x0f)
+	if pixel%4 == 0 {
+		return 0
+	}
+	return pixel
+}
+
+func (p *Ppu) fetchNametableByte() {
+	p.nametableByte = p.readMemory(NAMETABLES_START | (p.v & 0x0fff))
+}
+
+// Each attribute byte covers 4x4 tiles, split into 2x2 quadrants:
+// bits 0-1 top left, 2-3 top right, 4-5 bottom left, 6-7 bottom right.
+func (p *Ppu) fetchAttributeByte() {
+	nametable := p.v & 0x0c00
+	coarseX := p.v & 0x001f
+	coarseY := (p.v >> 5) & 0x001f
+	address := NAMETABLES_START | ATTRIBUTE_TABLE_OFFSET | nametable | (coarseY/4)<<3 | coarseX/4
+	shift := (coarseY&0x02)<<1 | coarseX&0x02
+	p.attributeByte = (p.readMemory(address) >> shift) & 0x03
+}
+
+// Each tile in a pattern table is 16 bytes: 8 bytes for the low bit of each
+// row followed by 8 bytes for the high bit. The leftmost pixel is bit 7.
+func (p *Ppu) fetchPatternLow() {
+	p.patternLow = p.readMemory(p.backgroundPatternAddress())
+}
+
+func (p *Ppu) fetchPatternHigh() {
+	p.patternHigh = p.readMemory(p.backgroundPatternAddress() + 8)
+}
+
+func (p *Ppu) backgroundPatternAddress() uint16 {
+	fineY := (p.v >> 12) & 0x07
+	return p.backgroundPatternTable() + uint16(p.nametableByte)*16 + fineY
+}
+
+// Load the fetched tile into the bottom 32 bits of tileData.
+func (p *Ppu) storeTileData() {
+	var data uint32
+	for bit := 7; bit >= 0; bit-- {
+		value := ((p.patternHigh>>bit)&1)<<1 | (p.patternLow>>bit)&1
+		data = (data << 4) | uint32(p.attributeByte<<2|value)
+	}
+	p.tileData |= uint64(data)
+}
+
+// Move v to the next tile, wrapping into the horizontally adjacent nametable.
+func (p *Ppu) incrementX() {
+	if p.v&0x001f == 31 {
+		p.v &^= 0x001f
+		p.v ^= 0x0400
+	} else {
+		p.v++
+	}
+}
+
+// Move v to the next row of pixels, wrapping into the vertically adjacent nametable.
+// There are only 30 rows of tiles. If coarse y is set past that (to 30 or 31) it
+// reads attribute bytes as tiles and wraps without switching nametables.
+func (p *Ppu) incrementY() {
+	if p.v&0x7000 != 0x7000 {
+		p.v += 0x1000
+		return
+	}
+
+	p.v &^= 0x7000
+	coarseY := (p.v & 0x03e0) >> 5
+	switch coarseY {
+	case 29:
+		coarseY = 0
+		p.v ^= 0x0800
+	case 31:
+		coarseY = 0
+	default:
+		coarseY++
+	}
+	p.v = (p.v &^ 0x03e0) | (coarseY << 5)
+}
+
+// Reset the horizontal position (coarse x and nametable x) from t.
+func (p *Ppu) copyX() {
+	p.v = (p.v &^ 0x041f) | (p.t & 0x041f)
+}
+
+// Reset the vertical position (fine y, coarse y and nametable y) from t.
+func (p *Ppu) copyY() {
+	p.v = (p.v &^ 0x7be0) | (p.t & 0x7be0)
+}
+
+func (p *Ppu) backgroundPatternTable() uint16 {
+	if p.ctrl&CTRL_BACKGROUND_TABLE != 0 {
+		return 0x1000
+	}
+	return 0x0000
+}
+
+// Look up a color in palette ram. Index 0 of every palette is the shared backdrop color.
+func (p *Ppu) paletteColor(index uint8) uint8 {
+	if index%4 == 0 {
+		index = 0
+	}
+	return p.readMemory(PALETTE_TABLE_START+uint16(index)) & 0x3f
+}
+
+// Returns the PPUMASK emphasis bits in Palette order: bit 0 is red, bit 1 green
+// and bit 2 blue. PAL consoles swap the red and green bits.
+//
+// See: https://www.nesdev.org/wiki/PPU_registers#Color_control
+func (p *Ppu) emphasis() uint8 {
+	emphasis := p.mask >> 5
+	if p.Region == REGION_PAL {
+		emphasis = (emphasis & 0x04) | (emphasis&0x01)<<1 | (emphasis&0x02)>>1
+	}
+	return emphasis
+}
+
+// Find the first 8 sprites in oam that are on the given scanline.
+//
+// Sprites are drawn one line below their y coordinate, so nothing is ever drawn on line 0.
+// See: https://www.nesdev.org/wiki/PPU_sprite_evaluation
+func (p *Ppu) evaluateSprites(scanline int) []sprite {
+	height := p.spriteHeight()
+	onScanline := func(y uint8) bool {
+		row := scanline - 1 - int(y)
+		return row >= 0 && row < height
+	}
+
+	sprites := make([]sprite, 0, MAX_SPRITES_PER_SCANLINE)
+	n := 0
+	for ; n < 64 && len(sprites) < MAX_SPRITES_PER_SCANLINE; n++ {
+		entry := p.oamData[n*4 : n*4+4]
+		if onScanline(entry[0]) {
+			sprites = append(sprites, sprite{index: n, y: entry[0], tile: entry[1], attributes: entry[2], x: entry[3]})
+		}
+	}
+
+	// Once 8 sprites are found, the hardware keeps looking for a 9th to set the
+	// overflow flag. It's supposed to keep checking y coordinates, but a bug
+	// increments the byte offset along with the sprite number, so it ends up
+	// treating tile numbers, attributes and x coordinates as y coordinates.
+	m := 0
+	for ; n < 64; n++ {
+		if onScanline(p.oamData[n*4+m]) {
+			p.status |= STATUS_SPRITE_OVERFLOW
+			break
+		}
+		m = (m + 1) % 4
+	}
+
+	return sprites
+}
+
+// Each sprite gets 8 dots: two garbage nametable fetches, then the low and high
+// pattern bytes for the row of the sprite on the next line.
+func (p *Ppu) fetchSpritePattern(slot int, dot int) {
+	if slot >= len(p.lineSprites) {
+		return
+	}
+	s := &p.lineSprites[slot]
+
+	// This runs on the line before the sprite is drawn, so there's no - 1 here.
+	row := p.Scanline - int(s.y)
+	if s.attributes&SPRITE_FLIP_VERTICAL != 0 {
+		row = p.spriteHeight() - 1 - row
+	}
+	address := p.spritePatternTable(s.tile) + p.spriteTile(s.tile, row)*16 + uint16(row%8)
+
+	switch dot {
+	case 4:
+		s.patternLow = p.readMemory(address)
+	case 6:
+		s.patternHigh = p.readMemory(address + 8)
+	}
+}
+
+// Returns the index into palette ram of the front-most opaque sprite pixel at the given
+// x coordinate (or 0 if there isn't one), whether that sprite is behind the background,
+// and whether sprite 0 has an opaque pixel there.
+func (p *Ppu) spritePixel(x int) (uint8, bool, bool) {
+	var color uint8
+	var behind, sprite0 bool
+	for _, s := range p.lineSprites {
+		column := x - int(s.x)
+		if column < 0 || column >= 8 {
+			continue
+		}
+		if s.attributes&SPRITE_FLIP_HORIZONTAL != 0 {
+			column = 7 - column
+		}
+
+		bit := 7 - uint(column)
+		value := ((s.patternHigh>>bit)&1)<<1 | (s.patternLow>>bit)&1
+		if value == 0 {
+			continue
+		}
+		if s.index == 0 {
+			sprite0 = true
+		}
+		// Lower oam indexes are in front, even if they're behind the background.
+		if color == 0 {
+			color = 0x10 + (s.attributes&SPRITE_PALETTE)*4 + value
+			behind = s.attributes&SPRITE_BEHIND != 0
+		}
+	}
+	return color, behind, sprite0
+}
+
+func (p *Ppu) spriteHeight() int {
+	if p.ctrl&CTRL_SPRITE_SIZE != 0 {
+		return 16
+	}
+	return 8
+}
+
+// 8x16 sprites pick their pattern table with bit 0 of the tile number and ignore PPUCTRL.
+func (p *Ppu) spritePatternTable(tile uint8) uint16 {
+	if p.spriteHeight() == 16 {
+		return uint16(tile&0x01) * 0x1000
+	}
+	if p.ctrl&CTRL_SPRITE_TABLE != 0 {
+		return 0x1000
+	}
+	return 0x0000
+}
+
+// 8x16 sprites use a pair of tiles: the even one on top and the odd one on the bottom.
+func (p *Ppu) spriteTile(tile uint8, row int) uint16 {
+	if p.spriteHeight() == 16 {
+		return uint16(tile&0xfe) + uint16(row/8)
+	}
+	return uint16(tile)
+}
+
+func (p *Ppu) showSprites(x int) bool {
+	if p.mask&MASK_SHOW_SPRITES == 0 {
+		return false
+	}
+	return x >= 8 || p.mask&MASK_SHOW_SPRITES_LEFT != 0
+}
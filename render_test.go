@@ -0,0 +1,748 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Run `go test -run TestRender -update` to regenerate the golden images after
+// an intentional change to the renderer. Look at the new images before committing them!
+var update = flag.Bool("update", false, "update golden images in testdata")
+
+// Test tiles:
+// 0: empty
+// 1-3: solid squares of color 1-3
+// 4: a diagonal stripe of color 3 on color 1, useful for seeing fine scroll and flips
+var testTiles = [][16]uint8{
+	{},
+	{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x01},
+}
+
+// Set up pattern tables and palettes shared by the rendering tests.
+func newTestPpu() Ppu {
+	ppu := Ppu{}
+	for i, tile := range testTiles {
+		copy(ppu.chrRam[i*16:], tile[:])
+		copy(ppu.chrRam[0x1000+i*16:], tile[:])
+	}
+	palettes := []uint8{
+		0x0f, 0x16, 0x27, 0x30, // black, red, orange, white
+		0x0f, 0x1a, 0x2a, 0x3a, // greens
+		0x0f, 0x12, 0x22, 0x32, // blues
+		0x0f, 0x14, 0x24, 0x34, // purples
+	}
+	for i, color := range palettes {
+		ppu.writeMemory(PALETTE_TABLE_START+uint16(i), color)
+		ppu.writeMemory(PALETTE_TABLE_START+0x10+uint16(i), color)
+	}
+	return ppu
+}
+
+// Fill a nametable with a repeating pattern of tiles and give each 2x2 group of
+// tiles a different palette.
+func fillTestNametable(ppu *Ppu, nametable uint16, tileOffset int) {
+	base := NAMETABLES_START + nametable*0x0400
+	for i := uint16(0); i < 32*30; i++ {
+		tile := (int(i%32+i/32) + tileOffset) % len(testTiles)
+		ppu.writeMemory(base+i, uint8(tile))
+	}
+	for i := uint16(0); i < 64; i++ {
+		ppu.writeMemory(base+ATTRIBUTE_TABLE_OFFSET+i, 0xe4)
+	}
+}
+
+// Run until a whole frame has been drawn. The first frame after power on is thrown
+// away since nothing was fetched for its first line and t was never copied into v.
+func renderFrame(ppu *Ppu) {
+	frame := ppu.Frame
+	for ppu.Frame < frame+2 {
+		ppu.Tick(1)
+	}
+}
+
+func TestRenderBackground(t *testing.T) {
+	t.Run("Background", func(t *testing.T) {
+		ppu := newTestPpu()
+		fillTestNametable(&ppu, 0, 0)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT
+		renderFrame(&ppu)
+
+		AssertGoldenImage(t, &ppu.FrameBuffer, "background.png")
+	})
+
+	t.Run("Scrolled", func(t *testing.T) {
+		ppu := newTestPpu()
+		ppu.Mirroring = VERTICAL_MIRRORING
+		fillTestNametable(&ppu, 0, 0)
+		fillTestNametable(&ppu, 1, 2)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT
+		ppu.WriteRegister(PPUSCROLL, 123)
+		ppu.WriteRegister(PPUSCROLL, 45)
+		renderFrame(&ppu)
+
+		AssertGoldenImage(t, &ppu.FrameBuffer, "background_scrolled.png")
+	})
+
+	t.Run("Background disabled", func(t *testing.T) {
+		ppu := newTestPpu()
+		fillTestNametable(&ppu, 0, 0)
+		renderFrame(&ppu)
+
+		for _, x := range []int{0, 100, 255} {
+			AssertPixel(t, &ppu.FrameBuffer, x, 100, 0x0f)
+		}
+	})
+
+	t.Run("Left column hidden", func(t *testing.T) {
+		ppu := newTestPpu()
+		ppu.writeMemory(NAMETABLES_START, 3)
+		ppu.writeMemory(NAMETABLES_START+1, 3)
+		ppu.mask = MASK_SHOW_BACKGROUND
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 7, 0, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 8, 0, 0x30)
+	})
+}
+
+func TestBackgroundPixels(t *testing.T) {
+	newBackgroundPpu := func() Ppu {
+		ppu := newTestPpu()
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT
+		return ppu
+	}
+
+	t.Run("Pattern bits", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		ppu.writeMemory(NAMETABLES_START, 4)
+		renderFrame(&ppu)
+
+		// Row 0 is color 3 in the first column and color 1 everywhere else.
+		AssertPixel(t, &ppu.FrameBuffer, 0, 0, 0x30)
+		AssertPixel(t, &ppu.FrameBuffer, 1, 0, 0x16)
+		// Row 1 is color 3 in the second column.
+		AssertPixel(t, &ppu.FrameBuffer, 1, 1, 0x30)
+	})
+
+	t.Run("Attribute quadrants", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		fillTestNametable(&ppu, 0, 3)
+		for i := uint16(0); i < 32*30; i++ {
+			ppu.writeMemory(NAMETABLES_START+i, 3)
+		}
+		renderFrame(&ppu)
+
+		// 0xe4 = palette 0 top left, 1 top right, 2 bottom left, 3 bottom right.
+		AssertPixel(t, &ppu.FrameBuffer, 0, 0, 0x30)
+		AssertPixel(t, &ppu.FrameBuffer, 16, 0, 0x3a)
+		AssertPixel(t, &ppu.FrameBuffer, 0, 16, 0x32)
+		AssertPixel(t, &ppu.FrameBuffer, 16, 16, 0x34)
+	})
+
+	t.Run("Pattern table select", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		ppu.writeMemory(NAMETABLES_START, 1)
+		// Make tile 1 in the second table color 2 instead of color 1.
+		ppu.chrRam[0x1000+16] = 0x00
+		ppu.chrRam[0x1000+16+8] = 0xff
+		ppu.ctrl = CTRL_BACKGROUND_TABLE
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 0, 0x27)
+	})
+
+	t.Run("Fine scroll", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		ppu.writeMemory(NAMETABLES_START+1, 3)
+		ppu.WriteRegister(PPUSCROLL, 3)
+		ppu.WriteRegister(PPUSCROLL, 0)
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 4, 0, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 5, 0, 0x30)
+	})
+
+	t.Run("Base nametable", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		ppu.Mirroring = VERTICAL_MIRRORING
+		ppu.writeMemory(0x2400, 3)
+		ppu.WriteRegister(PPUCTRL, 0x01)
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 0, 0x30)
+	})
+
+	t.Run("Scrolling into the next nametable", func(t *testing.T) {
+		ppu := newBackgroundPpu()
+		ppu.Mirroring = HORIZONTAL_MIRRORING
+		ppu.writeMemory(0x2800, 3)
+		ppu.WriteRegister(PPUSCROLL, 0)
+		ppu.WriteRegister(PPUSCROLL, 200)
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 39, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 0, 40, 0x30)
+	})
+
+}
+
+func TestScrollIncrements(t *testing.T) {
+	t.Run("Coarse x", func(t *testing.T) {
+		ppu := Ppu{v: 0x0005}
+		ppu.incrementX()
+		AssertVramAddr(t, &ppu, 0x0006)
+	})
+
+	t.Run("Coarse x wraps into the next nametable", func(t *testing.T) {
+		ppu := Ppu{v: 0x001f}
+		ppu.incrementX()
+		AssertVramAddr(t, &ppu, 0x0400)
+
+		ppu.v = 0x041f
+		ppu.incrementX()
+		AssertVramAddr(t, &ppu, 0x0000)
+	})
+
+	t.Run("Fine y", func(t *testing.T) {
+		ppu := Ppu{v: 0x1000}
+		ppu.incrementY()
+		AssertVramAddr(t, &ppu, 0x2000)
+	})
+
+	t.Run("Coarse y", func(t *testing.T) {
+		ppu := Ppu{v: 0x7000}
+		ppu.incrementY()
+		AssertVramAddr(t, &ppu, 0x0020)
+	})
+
+	t.Run("Coarse y wraps into the next nametable at row 29", func(t *testing.T) {
+		ppu := Ppu{v: 0x73a0}
+		ppu.incrementY()
+		AssertVramAddr(t, &ppu, 0x0800)
+	})
+
+	t.Run("Coarse y wraps without switching nametables at row 31", func(t *testing.T) {
+		// Rows 30 and 31 are the attribute table, which games sometimes scroll into.
+		ppu := Ppu{v: 0x73e0}
+		ppu.incrementY()
+		AssertVramAddr(t, &ppu, 0x0000)
+	})
+
+	t.Run("Copy x", func(t *testing.T) {
+		ppu := Ppu{t: 0x041f, v: 0x7be0}
+		ppu.copyX()
+		AssertVramAddr(t, &ppu, 0x7fff)
+	})
+
+	t.Run("Copy y", func(t *testing.T) {
+		ppu := Ppu{t: 0x7be0, v: 0x041f}
+		ppu.copyY()
+		AssertVramAddr(t, &ppu, 0x7fff)
+	})
+}
+
+func TestScrollTiming(t *testing.T) {
+	newScrollPpu := func() Ppu {
+		ppu := newTestPpu()
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT
+		ppu.WriteRegister(PPUSCROLL, 0x7d)
+		ppu.WriteRegister(PPUSCROLL, 0x5e)
+		return ppu
+	}
+
+	t.Run("Horizontal position is reloaded on dot 257", func(t *testing.T) {
+		ppu := newScrollPpu()
+		ppu.Scanline, ppu.Dot = 10, 255
+		ppu.Tick(1)
+		if ppu.v&0x041f == ppu.t&0x041f {
+			t.Errorf("Expected horizontal position not to be copied before dot 257")
+		}
+
+		ppu.Tick(2)
+		if ppu.v&0x041f != ppu.t&0x041f {
+			t.Errorf("Expected horizontal position %#x but was %#x", ppu.t&0x041f, ppu.v&0x041f)
+		}
+	})
+
+	t.Run("Vertical position is reloaded on the pre-render line", func(t *testing.T) {
+		ppu := newScrollPpu()
+		ppu.Scanline, ppu.Dot = PRE_RENDER_SCANLINE, COPY_Y_START-1
+		ppu.Tick(1)
+		if ppu.v&0x7be0 != ppu.t&0x7be0 {
+			t.Errorf("Expected vertical position %#x but was %#x", ppu.t&0x7be0, ppu.v&0x7be0)
+		}
+	})
+
+	t.Run("Vertical position isn't reloaded on visible lines", func(t *testing.T) {
+		ppu := newScrollPpu()
+		ppu.Scanline, ppu.Dot = 10, COPY_Y_START-1
+		ppu.Tick(COPY_Y_END - COPY_Y_START + 1)
+		if ppu.v&0x7be0 == ppu.t&0x7be0 {
+			t.Errorf("Expected vertical position not to be copied")
+		}
+	})
+
+	t.Run("Mid-frame scroll change", func(t *testing.T) {
+		ppu := newTestPpu()
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT
+		ppu.Mirroring = VERTICAL_MIRRORING
+		// Left nametable is empty, right nametable is solid.
+		for i := uint16(0); i < 32*30; i++ {
+			ppu.writeMemory(0x2400+i, 3)
+		}
+		renderFrame(&ppu)
+
+		// Switch to the right nametable part way through line 100. The new
+		// horizontal position is picked up at the end of the line.
+		for ppu.Scanline != 100 || ppu.Dot != 200 {
+			ppu.Tick(1)
+		}
+		ppu.WriteRegister(PPUCTRL, 0x01)
+		for ppu.Scanline != 0 {
+			ppu.Tick(1)
+		}
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 100, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 255, 100, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 0, 101, 0x30)
+		AssertPixel(t, &ppu.FrameBuffer, 0, 239, 0x30)
+	})
+
+	t.Run("PPUDATA access while rendering", func(t *testing.T) {
+		// Instead of the normal increment, both coarse x and y are bumped.
+		ppu := newScrollPpu()
+		ppu.Scanline, ppu.Dot = 10, 100
+		ppu.v = 0x0000
+		ppu.ReadRegister(PPUDATA)
+		AssertVramAddr(t, &ppu, 0x1001)
+	})
+
+	t.Run("PPUDATA access during vblank", func(t *testing.T) {
+		ppu := newScrollPpu()
+		ppu.Scanline, ppu.Dot = VBLANK_SCANLINE, 100
+		ppu.v = 0x0000
+		ppu.ReadRegister(PPUDATA)
+		AssertVramAddr(t, &ppu, 0x0001)
+	})
+}
+
+func TestColorEffects(t *testing.T) {
+	newEffectsPpu := func(mask uint8) Ppu {
+		ppu := newTestPpu()
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT | mask
+		// Color 1 of the first palette is red (0x16).
+		for i := uint16(0); i < 32*30; i++ {
+			ppu.writeMemory(NAMETABLES_START+i, 1)
+		}
+		return ppu
+	}
+
+	t.Run("Greyscale", func(t *testing.T) {
+		ppu := newEffectsPpu(MASK_GREYSCALE)
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 0, 0x10)
+	})
+
+	t.Run("Emphasis", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			mask     uint8
+			emphasis uint8
+		}{
+			{"Red", MASK_EMPHASIZE_RED, 0x01},
+			{"Green", MASK_EMPHASIZE_GREEN, 0x02},
+			{"Blue", MASK_EMPHASIZE_BLUE, 0x04},
+			{"All", MASK_EMPHASIZE_RED | MASK_EMPHASIZE_GREEN | MASK_EMPHASIZE_BLUE, 0x07},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				ppu := newEffectsPpu(test.mask)
+				renderFrame(&ppu)
+
+				AssertEmphasizedPixel(t, &ppu.FrameBuffer, 0, 0, 0x16, test.emphasis)
+			})
+		}
+	})
+
+	t.Run("PAL swaps red and green", func(t *testing.T) {
+		ppu := newEffectsPpu(MASK_EMPHASIZE_RED)
+		ppu.Region = REGION_PAL
+		renderFrame(&ppu)
+		AssertEmphasizedPixel(t, &ppu.FrameBuffer, 0, 0, 0x16, 0x02)
+
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_EMPHASIZE_GREEN | MASK_EMPHASIZE_BLUE
+		renderFrame(&ppu)
+		AssertEmphasizedPixel(t, &ppu.FrameBuffer, 8, 0, 0x16, 0x05)
+	})
+
+	t.Run("Emphasis on the backdrop", func(t *testing.T) {
+		// Rendering doesn't need to be enabled to emphasize the backdrop.
+		ppu := newTestPpu()
+		ppu.mask = MASK_EMPHASIZE_BLUE
+		renderFrame(&ppu)
+
+		AssertEmphasizedPixel(t, &ppu.FrameBuffer, 100, 100, 0x0f, 0x04)
+	})
+
+	t.Run("Changing mid-frame", func(t *testing.T) {
+		ppu := newEffectsPpu(0)
+		renderFrame(&ppu)
+		for ppu.Scanline != 120 {
+			ppu.Tick(1)
+		}
+		ppu.WriteRegister(PPUMASK, MASK_SHOW_BACKGROUND|MASK_SHOW_BACKGROUND_LEFT|MASK_GREYSCALE|MASK_EMPHASIZE_RED)
+		for ppu.Scanline != 0 {
+			ppu.Tick(1)
+		}
+
+		AssertPixel(t, &ppu.FrameBuffer, 0, 119, 0x16)
+		AssertEmphasizedPixel(t, &ppu.FrameBuffer, 0, 120, 0x10, 0x01)
+	})
+}
+
+// Test helpers
+func AssertPixel(t *testing.T, frame *FrameBuffer, x int, y int, color uint8) {
+	AssertEmphasizedPixel(t, frame, x, y, color, 0)
+}
+
+func AssertEmphasizedPixel(t *testing.T, frame *FrameBuffer, x int, y int, color uint8, emphasis uint8) {
+	actual := frame.Pixel(x, y)
+	expected := uint16(emphasis)<<6 | uint16(color)
+	if actual != expected {
+		t.Errorf("Expected pixel at (%d, %d) to be %#x but was %#x", x, y, expected, actual)
+	}
+}
+
+func AssertGoldenImage(t *testing.T, frame *FrameBuffer, name string) {
+	path := filepath.Join("testdata", name)
+	var actual bytes.Buffer
+	img := frame.Image(defaultPalette)
+	if err := png.Encode(&actual, img); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile(path, actual.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	golden, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := golden.Bounds()
+	if bounds != image.Rect(0, 0, FRAME_WIDTH, FRAME_HEIGHT) {
+		t.Fatalf("Expected golden image to be %dx%d but was %dx%d", FRAME_WIDTH, FRAME_HEIGHT, bounds.Dx(), bounds.Dy())
+	}
+	mismatches := 0
+	for y := 0; y < FRAME_HEIGHT; y++ {
+		for x := 0; x < FRAME_WIDTH; x++ {
+			r, g, b, a := golden.At(x, y).RGBA()
+			pixel := img.RGBAAt(x, y)
+			if uint8(r>>8) != pixel.R || uint8(g>>8) != pixel.G || uint8(b>>8) != pixel.B || uint8(a>>8) != pixel.A {
+				if mismatches == 0 {
+					t.Errorf("First mismatch at (%d, %d): expected %v but was %v", x, y, golden.At(x, y), pixel)
+				}
+				mismatches++
+			}
+		}
+	}
+	if mismatches > 0 {
+		t.Errorf("%d pixels don't match %s", mismatches, path)
+	}
+}
+
+// Write a sprite into oam. Sprites are drawn one line below y.
+func setSprite(ppu *Ppu, index int, x uint8, y uint8, tile uint8, attributes uint8) {
+	copy(ppu.oamData[index*4:], []uint8{y, tile, attributes, x})
+}
+
+// Hide every sprite below the bottom of the screen.
+func clearSprites(ppu *Ppu) {
+	for i := range ppu.oamData {
+		ppu.oamData[i] = 0xff
+	}
+}
+
+func TestRenderSprites(t *testing.T) {
+	t.Run("Sprites", func(t *testing.T) {
+		ppu := newTestPpu()
+		fillTestNametable(&ppu, 0, 0)
+		clearSprites(&ppu)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT | MASK_SHOW_SPRITES | MASK_SHOW_SPRITES_LEFT
+		// A row of each flip combination in each palette.
+		flips := []uint8{0, SPRITE_FLIP_HORIZONTAL, SPRITE_FLIP_VERTICAL, SPRITE_FLIP_HORIZONTAL | SPRITE_FLIP_VERTICAL}
+		for i, flip := range flips {
+			setSprite(&ppu, i, uint8(20+i*12), 20, 4, flip|uint8(i))
+		}
+		// The same in front of and behind the background.
+		setSprite(&ppu, 4, 20, 40, 3, 0x01)
+		setSprite(&ppu, 5, 32, 40, 3, 0x01|SPRITE_BEHIND)
+		// Overlapping sprites, lower indexes are on top.
+		setSprite(&ppu, 6, 60, 60, 3, 0x02)
+		setSprite(&ppu, 7, 64, 64, 3, 0x03)
+		renderFrame(&ppu)
+
+		AssertGoldenImage(t, &ppu.FrameBuffer, "sprites.png")
+	})
+
+	t.Run("8x16 sprites", func(t *testing.T) {
+		ppu := newTestPpu()
+		clearSprites(&ppu)
+		ppu.ctrl = CTRL_SPRITE_SIZE
+		ppu.mask = MASK_SHOW_SPRITES | MASK_SHOW_SPRITES_LEFT
+		// Tiles 4 (top) and 5 (bottom) from the first table, and 0 (empty) and 1 from the second table.
+		copy(ppu.chrRam[5*16:], testTiles[2][:])
+		setSprite(&ppu, 0, 20, 20, 4, 0)
+		setSprite(&ppu, 1, 32, 20, 4, SPRITE_FLIP_VERTICAL)
+		setSprite(&ppu, 2, 44, 20, 1, 0x01)
+		setSprite(&ppu, 3, 56, 20, 1, 0x01|SPRITE_FLIP_VERTICAL)
+		renderFrame(&ppu)
+
+		AssertGoldenImage(t, &ppu.FrameBuffer, "sprites_8x16.png")
+	})
+}
+
+func TestEvaluateSprites(t *testing.T) {
+	t.Run("On scanline", func(t *testing.T) {
+		ppu := Ppu{}
+		clearSprites(&ppu)
+		setSprite(&ppu, 3, 0, 10, 0, 0)
+
+		AssertSpriteCount(t, ppu.evaluateSprites(10), 0)
+		AssertSpriteCount(t, ppu.evaluateSprites(11), 1)
+		AssertSpriteCount(t, ppu.evaluateSprites(18), 1)
+		AssertSpriteCount(t, ppu.evaluateSprites(19), 0)
+
+		ppu.ctrl = CTRL_SPRITE_SIZE
+		AssertSpriteCount(t, ppu.evaluateSprites(26), 1)
+		AssertSpriteCount(t, ppu.evaluateSprites(27), 0)
+	})
+
+	t.Run("Eight sprite limit", func(t *testing.T) {
+		ppu := Ppu{}
+		clearSprites(&ppu)
+		for i := 0; i < 10; i++ {
+			setSprite(&ppu, i*2, uint8(i), 10, 0, 0)
+		}
+		sprites := ppu.evaluateSprites(11)
+
+		AssertSpriteCount(t, sprites, 8)
+		if sprites[7].index != 14 {
+			t.Errorf("Expected the last sprite to be oam entry %d but was %d", 14, sprites[7].index)
+		}
+		AssertSpriteOverflow(t, &ppu, true)
+	})
+
+	t.Run("No overflow with eight sprites", func(t *testing.T) {
+		ppu := Ppu{}
+		clearSprites(&ppu)
+		for i := 0; i < 8; i++ {
+			setSprite(&ppu, i, 0, 10, 0, 0)
+		}
+		ppu.evaluateSprites(11)
+
+		AssertSpriteOverflow(t, &ppu, false)
+	})
+
+	t.Run("Overflow bug misses a sprite", func(t *testing.T) {
+		ppu := Ppu{}
+		clearSprites(&ppu)
+		for i := 0; i < 8; i++ {
+			setSprite(&ppu, i, 0, 10, 0, 0)
+		}
+		// The 9th sprite's y is checked, but after that the hardware looks at
+		// byte 1 of sprite 10 (the tile) instead of its y coordinate.
+		setSprite(&ppu, 9, 0, 10, 0xff, 0)
+		ppu.evaluateSprites(11)
+
+		AssertSpriteOverflow(t, &ppu, false)
+	})
+
+	t.Run("Overflow bug finds a false positive", func(t *testing.T) {
+		ppu := Ppu{}
+		clearSprites(&ppu)
+		for i := 0; i < 8; i++ {
+			setSprite(&ppu, i, 0, 10, 0, 0)
+		}
+		// Sprite 9 isn't on the line, but its tile number looks like it is.
+		setSprite(&ppu, 9, 0, 0xff, 10, 0)
+		ppu.evaluateSprites(11)
+
+		AssertSpriteOverflow(t, &ppu, true)
+	})
+}
+
+func TestSpritePriority(t *testing.T) {
+	t.Run("Behind opaque background", func(t *testing.T) {
+		ppu := newTestPpu()
+		clearSprites(&ppu)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT | MASK_SHOW_SPRITES | MASK_SHOW_SPRITES_LEFT
+		setSprite(&ppu, 0, 0, 0, 3, SPRITE_BEHIND|0x01)
+		ppu.writeMemory(NAMETABLES_START, 1)
+		renderFrame(&ppu)
+
+		// The background is opaque in the first tile and transparent in the second.
+		AssertPixel(t, &ppu.FrameBuffer, 0, 1, 0x16)
+		setSprite(&ppu, 0, 8, 0, 3, SPRITE_BEHIND|0x01)
+		renderFrame(&ppu)
+		AssertPixel(t, &ppu.FrameBuffer, 8, 1, 0x3a)
+	})
+
+	t.Run("Front sprite hides sprites behind it even when it's behind the background", func(t *testing.T) {
+		ppu := newTestPpu()
+		clearSprites(&ppu)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_SPRITES
+		setSprite(&ppu, 0, 8, 0, 3, SPRITE_BEHIND|0x01)
+		setSprite(&ppu, 1, 8, 0, 3, 0x02)
+		ppu.writeMemory(NAMETABLES_START+1, 1)
+		renderFrame(&ppu)
+
+		// Background color 1 wins over both sprites.
+		AssertPixel(t, &ppu.FrameBuffer, 8, 1, 0x16)
+	})
+
+	t.Run("Left column hidden", func(t *testing.T) {
+		ppu := newTestPpu()
+		clearSprites(&ppu)
+		ppu.mask = MASK_SHOW_SPRITES
+		setSprite(&ppu, 0, 4, 0, 3, 0)
+		renderFrame(&ppu)
+
+		AssertPixel(t, &ppu.FrameBuffer, 7, 1, 0x0f)
+		AssertPixel(t, &ppu.FrameBuffer, 8, 1, 0x30)
+	})
+}
+
+func TestSprite0Hit(t *testing.T) {
+	// Tick until the given dot on the given scanline.
+	tickTo := func(ppu *Ppu, scanline int, dot int) {
+		ppu.Tick((scanline-ppu.Scanline)*DOTS_PER_SCANLINE + dot - ppu.Dot)
+	}
+	newHitPpu := func(x uint8, y uint8) Ppu {
+		ppu := newTestPpu()
+		clearSprites(&ppu)
+		for i := uint16(0); i < 32*30; i++ {
+			ppu.writeMemory(NAMETABLES_START+i, 3)
+		}
+		setSprite(&ppu, 0, x, y, 3, 0)
+		ppu.mask = MASK_SHOW_BACKGROUND | MASK_SHOW_BACKGROUND_LEFT | MASK_SHOW_SPRITES | MASK_SHOW_SPRITES_LEFT
+		return ppu
+	}
+
+	t.Run("Hit on the first overlapping dot", func(t *testing.T) {
+		ppu := newHitPpu(100, 50)
+		// Sprite's first pixel is x=100 on line 51, which is output on dot 101.
+		tickTo(&ppu, 51, 100)
+		AssertSprite0Hit(t, &ppu, false)
+
+		ppu.Tick(1)
+		AssertSprite0Hit(t, &ppu, true)
+	})
+
+	t.Run("Hit on dot 1", func(t *testing.T) {
+		ppu := newHitPpu(0, 50)
+		tickTo(&ppu, 51, 0)
+		AssertSprite0Hit(t, &ppu, false)
+
+		ppu.Tick(1)
+		AssertSprite0Hit(t, &ppu, true)
+	})
+
+	t.Run("Transparent pixels don't hit", func(t *testing.T) {
+		ppu := newHitPpu(100, 50)
+		// Clear the first four columns of the sprite's tile in the first table.
+		for row := 0; row < 8; row++ {
+			ppu.chrRam[3*16+row] = 0x0f
+			ppu.chrRam[3*16+8+row] = 0x0f
+		}
+		// Give the background its own solid tile so it's unaffected.
+		copy(ppu.chrRam[0x1000+5*16:], testTiles[3][:])
+		ppu.ctrl = CTRL_BACKGROUND_TABLE
+		for i := uint16(0); i < 32*30; i++ {
+			ppu.writeMemory(NAMETABLES_START+i, 5)
+		}
+		tickTo(&ppu, 51, 104)
+		AssertSprite0Hit(t, &ppu, false)
+
+		ppu.Tick(1)
+		AssertSprite0Hit(t, &ppu, true)
+	})
+
+	t.Run("No hit at x=255", func(t *testing.T) {
+		ppu := newHitPpu(255, 50)
+		renderFrame(&ppu)
+		AssertSprite0Hit(t, &ppu, false)
+	})
+
+	t.Run("No hit in the hidden left column", func(t *testing.T) {
+		ppu := newHitPpu(4, 50)
+		ppu.mask &^= MASK_SHOW_SPRITES_LEFT
+		tickTo(&ppu, 51, 8)
+		AssertSprite0Hit(t, &ppu, false)
+
+		// The first visible pixel of the sprite is x=8.
+		ppu.Tick(1)
+		AssertSprite0Hit(t, &ppu, true)
+	})
+
+	t.Run("No hit with background disabled", func(t *testing.T) {
+		ppu := newHitPpu(100, 50)
+		ppu.mask &^= MASK_SHOW_BACKGROUND
+		renderFrame(&ppu)
+		AssertSprite0Hit(t, &ppu, false)
+	})
+
+	t.Run("Only sprite 0 hits", func(t *testing.T) {
+		ppu := newHitPpu(100, 50)
+		setSprite(&ppu, 0, 0, 0xff, 3, 0)
+		setSprite(&ppu, 1, 100, 50, 3, 0)
+		renderFrame(&ppu)
+		AssertSprite0Hit(t, &ppu, false)
+	})
+
+	t.Run("Cleared on the pre-render scanline", func(t *testing.T) {
+		ppu := newHitPpu(100, 50)
+		tickTo(&ppu, PRE_RENDER_SCANLINE, 0)
+		AssertSprite0Hit(t, &ppu, true)
+
+		ppu.Tick(1)
+		AssertSprite0Hit(t, &ppu, false)
+	})
+}
+
+func AssertSpriteCount(t *testing.T, sprites []sprite, count int) {
+	if len(sprites) != count {
+		t.Errorf("Expected %d sprites but there were %d", count, len(sprites))
+	}
+}
+
+func AssertSpriteOverflow(t *testing.T, ppu *Ppu, status bool) {
+	actual := ppu.status&STATUS_SPRITE_OVERFLOW != 0
+	if actual != status {
+		t.Errorf("Expected sprite overflow status to be %t but was %t", status, actual)
+	}
+}
+
+func AssertSprite0Hit(t *testing.T, ppu *Ppu, status bool) {
+	actual := ppu.status&STATUS_SPRITE_ZERO_HIT != 0
+	if actual != status {
+		t.Errorf("Expected sprite 0 hit status to be %t but was %t", status, actual)
+	}
+}